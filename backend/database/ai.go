@@ -13,15 +13,18 @@ package database
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/sashabaranov/go-openai"
 )
 
+var ErrModelNotVisual = errors.New("model cannot describe images")
+
 // MARK: ROUTERS - GET
 
 // Get the prefilled Descriptions generated by Service's LLM Model of the Subject from the database.
@@ -33,7 +36,7 @@ func GetDescriptionsForSuspect(suspectUUID, modelName string, strict bool) ([]De
 		return nil, err
 	}
 
-	query := "SELECT UUID, Description, Prompt, Timestamp FROM descriptions WHERE SuspectUUID = $1 AND Service = $2 AND Model = $3"
+	query := "SELECT UUID, Description, Prompt, ProviderModel, Timestamp FROM descriptions WHERE SuspectUUID = $1 AND Service = $2 AND Model = $3"
 	rows, err := database.Query(query, suspectUUID, service.Name, modelName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get descriptions: %w", err)
@@ -46,7 +49,7 @@ func GetDescriptionsForSuspect(suspectUUID, modelName string, strict bool) ([]De
 			Service:     service.Name,
 			Model:       modelName,
 		}
-		err := rows.Scan(&d.UUID, &d.Description, &d.Prompt, &d.Timestamp)
+		err := rows.Scan(&d.UUID, &d.Description, &d.Prompt, &d.ProviderModel, &d.Timestamp)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan description row: %w", err)
 		}
@@ -72,7 +75,7 @@ func GetDescriptionsForSuspect(suspectUUID, modelName string, strict bool) ([]De
 // because there are not any pre-generated descriptions by requested model in the database.
 func GetAnyDescriptionsForSuspect(suspectUUID string) ([]Description, error) {
 	var descriptions []Description
-	query := "SELECT UUID, Description, Service, Model, Prompt, Timestamp FROM descriptions WHERE SuspectUUID = $1"
+	query := "SELECT UUID, Description, Service, Model, Prompt, ProviderModel, Timestamp FROM descriptions WHERE SuspectUUID = $1"
 	rows, err := database.Query(query, suspectUUID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get descriptions: %w", err)
@@ -81,7 +84,7 @@ func GetAnyDescriptionsForSuspect(suspectUUID string) ([]Description, error) {
 
 	for rows.Next() {
 		var d = Description{SuspectUUID: suspectUUID}
-		err := rows.Scan(&d.UUID, &d.Description, &d.Service, &d.Model, &d.Prompt, &d.Timestamp)
+		err := rows.Scan(&d.UUID, &d.Description, &d.Service, &d.Model, &d.Prompt, &d.ProviderModel, &d.Timestamp)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan description row: %w", err)
 		}
@@ -99,18 +102,28 @@ func GetAnyDescriptionsForSuspect(suspectUUID string) ([]Description, error) {
 	return descriptions, nil
 }
 
+// Get the Description by its UUID.
+func GetDescription(descriptionUUID string) (Description, error) {
+	var d = Description{UUID: descriptionUUID}
+	query := "SELECT SuspectUUID, Description, Service, Model, Prompt, ProviderModel, Timestamp FROM descriptions WHERE UUID = $1"
+	err := database.QueryRow(query, descriptionUUID).Scan(&d.SuspectUUID, &d.Description, &d.Service, &d.Model, &d.Prompt, &d.ProviderModel, &d.Timestamp)
+	return d, err
+}
+
 // MARK: ROUTER-GENERATE
 
 // Generate description of the Suspect's portrait.
+// Model must be Visual, otherwise it cannot see the portrait.
 func GenerateDescription(suspectUUID, modelName string) error {
+	model, err := GetModel(modelName)
+	if err != nil {
+		return err
+	}
 	service, err := GetServiceForModel(modelName)
 	if err != nil {
 		return err
 	}
 	fmt.Printf("Generating description using model %s on service %s\n", modelName, service.Name)
-	if service.Token == "" {
-		return fmt.Errorf("token for service %s not set", service.Name)
-	}
 
 	suspect, err := GetSuspect(suspectUUID)
 	if err != nil {
@@ -119,20 +132,21 @@ func GenerateDescription(suspectUUID, modelName string) error {
 	fmt.Println("Generating description for suspect:", suspect)
 
 	imgPath := filepath.Join("..", "front", "static", "suspects", suspect.Image)
-	text, prompt, err := DescribeImage(imgPath, modelName, service)
+	text, prompt, providerModel, err := DescribeImage(imgPath, model, service)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Generated description: %s\n\nPrompt used: %s\n\n", text, prompt)
+	fmt.Printf("Generated description by %s: %s\n\nPrompt used: %s\n\n", providerModel, text, prompt)
 	description := Description{
-		UUID:        uuid.New().String(),
-		SuspectUUID: suspectUUID,
-		Service:     service.Name,
-		Model:       modelName,
-		Description: text,
-		Prompt:      prompt,
-		Timestamp:   TimestampNow(),
+		UUID:          uuid.New().String(),
+		SuspectUUID:   suspectUUID,
+		Service:       service.Name,
+		Model:         modelName,
+		ProviderModel: providerModel,
+		Description:   text,
+		Prompt:        prompt,
+		Timestamp:     TimestampNow(),
 	}
 
 	fmt.Printf("--- Saving description: %s\n", description.Description)
@@ -144,6 +158,14 @@ func GenerateDescription(suspectUUID, modelName string) error {
 // Generate descriptions by Model for all suspects in the database.
 // Used by dev.go to populate the database with descriptions of all suspects by defined model.
 func GenerateDescriptionsForAllSuspects(modelName string, limit int) error {
+	model, err := GetModel(modelName)
+	if err != nil {
+		return err
+	}
+	if !model.Visual {
+		return fmt.Errorf("%w: %s", ErrModelNotVisual, model.Name)
+	}
+
 	suspects, err := GetAllSuspects()
 	if err != nil {
 		return err
@@ -171,20 +193,25 @@ func GenerateDescriptionsForAllSuspects(modelName string, limit int) error {
 	return nil
 }
 
-// MARK: OPENAI
+// MARK: LLM
 
 // Describe the image using the specified model.
-// Models must be one of visualModels.
+// Model must be Visual (see models table), otherwise ErrModelNotVisual is returned.
 //
-// Returns description, prompt used and error.
-func DescribeImage(imagePath string, model string, service Service) (string, string, error) {
-	if service.Token == "" {
-		return "", "", errors.New("token cannot be empty")
+// Returns description, prompt used, model which actually answered as reported by the Service and error.
+func DescribeImage(imagePath string, model Model, service Service) (string, string, string, error) {
+	if !model.Visual {
+		return "", "", "", fmt.Errorf("%w: %s (set Visual in models table if it can see images)", ErrModelNotVisual, model.Name)
+	}
+
+	provider, err := NewProvider(service)
+	if err != nil {
+		return "", "", "", err
 	}
 
 	imgBase64String, err := ImageToBase64(imagePath)
 	if err != nil {
-		return "", "", errors.New("failed to convert image to base64: " + err.Error())
+		return "", "", "", errors.New("failed to convert image to base64: " + err.Error())
 	}
 
 	prompt := `CONTEXT:
@@ -211,106 +238,258 @@ Do NOT include any disclaimers such as:
 Avoid all self-referential or refusal statements. Focus entirely on creative interpretation and description.
 
 `
-	config := openai.DefaultConfig(service.Token)
-	if service.URL.String != "" {
-		config.BaseURL = service.URL.String
-	}
-	client := openai.NewClientWithConfig(config)
-	resp, err := client.CreateChatCompletion(
+	resp, err := provider.Chat(
 		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4o20240806,
-			Messages: []openai.ChatCompletionMessage{
+		ChatRequest{
+			Model: model.Name,
+			Messages: []ChatMessage{
 				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-				{
-					Role: openai.ChatMessageRoleUser,
-					MultiContent: []openai.ChatMessagePart{
-						{
-							Type: openai.ChatMessagePartTypeImageURL,
-							ImageURL: &openai.ChatMessageImageURL{
-								URL:    fmt.Sprintf("data:image/jpeg;base64,%s", imgBase64String),
-								Detail: openai.ImageURLDetailHigh,
-							},
-						},
-					},
+					Role:  RoleUser,
+					Text:  prompt,
+					Image: imgBase64String,
 				},
 			},
 		},
 	)
 	if err != nil {
-		return "", "", err
+		return "", "", "", err
 	}
 
-	return resp.Choices[0].Message.Content, prompt, nil
+	return resp.Text, prompt, resp.Model, nil
+}
+
+const (
+	answerJSON = `ROLE: You are a senior decision maker.
+TASK: Answer the question YES or NO based on the previous information.
+Respond only with JSON object {"answer": "YES"} or {"answer": "NO"}. Do not write anything else.`
+	answerStrict = `You have to decide. Reply with exactly one word: YES or NO.
+No explanation, no punctuation, no other words. If you are not sure, choose the answer you lean towards.`
+)
+
+// Generate the Answer for the Round by the Model of its Game, based on one of the Descriptions of the criminal.
+// Errors which happen before the generation starts are published as EventError too, so nobody waits for nothing.
+func AnswerRound(roundUUID string) (Answer, error) {
+	question, description, model, service, err := prepareAnswer(roundUUID)
+	if err != nil {
+		PublishAnswerEvent(AnswerEvent{Type: EventError, RoundUUID: roundUUID, Text: err.Error()})
+		return Answer{}, err
+	}
+	return GenerateAnswerForRound(roundUUID, question, description, model, service)
+}
+
+// Get everything needed to answer the Round: question, Description of the criminal, Model and its Service.
+func prepareAnswer(roundUUID string) (string, Description, string, Service, error) {
+	var questionUUID, investigationUUID, criminalUUID, descriptionUUID, model, modeName string
+	query := `SELECT r.question_uuid, i.uuid, i.criminal_uuid, i.description_uuid, g.model, g.mode FROM rounds r
+		JOIN investigations i ON i.uuid = r.investigation_uuid
+		JOIN games g ON g.uuid = i.game_uuid
+		WHERE r.uuid = $1`
+	err := database.QueryRow(query, roundUUID).Scan(&questionUUID, &investigationUUID, &criminalUUID, &descriptionUUID, &model, &modeName)
+	if err != nil {
+		return "", Description{}, "", Service{}, fmt.Errorf("could not get round %s: %w", roundUUID, err)
+	}
+	if mode, err := GetGameMode(modeName); err == nil && mode.HumanWitness {
+		return "", Description{}, "", Service{}, fmt.Errorf("round %s is answered by the human witness", roundUUID)
+	}
+	if questionUUID == "" {
+		return "", Description{}, "", Service{}, fmt.Errorf("question of round %s was not chosen yet", roundUUID)
+	}
+	question, err := getQuestion(questionUUID)
+	if err != nil {
+		return "", Description{}, "", Service{}, fmt.Errorf("could not get question %s: %w", questionUUID, err)
+	}
+	service, err := GetServiceForModel(model)
+	if err != nil {
+		return "", Description{}, "", Service{}, fmt.Errorf("could not get service for model %s: %w", model, err)
+	}
+
+	// Every Round uses the same Description, the one chosen when the Investigation was created.
+	if descriptionUUID != "" {
+		description, err := GetDescription(descriptionUUID)
+		if err != nil {
+			return "", Description{}, "", Service{}, fmt.Errorf("could not get description %s: %w", descriptionUUID, err)
+		}
+		return question.English, description, model, service, nil
+	}
+
+	// Choosing failed when the Investigation was created, e.g. there was no Description yet.
+	description, fallback, err := chooseDescription(investigationUUID, criminalUUID, model)
+	if err != nil {
+		return "", Description{}, "", Service{}, err
+	}
+	query = "UPDATE investigations SET description_uuid = $1, description_fallback = $2 WHERE uuid = $3 AND description_uuid = ''"
+	_, err = database.Exec(query, description.UUID, fallback, investigationUUID)
+	if err != nil {
+		return "", Description{}, "", Service{}, fmt.Errorf("could not save description of investigation: %w", err)
+	}
+	return question.English, description, model, service, nil
+}
+
+// Choose the Description of the criminal the witness will use during the whole Investigation.
+// Descriptions by the Model of the Game are preferred. If there are none, Description by any other Model
+// is used and fallback is true - the witness then sees the criminal through eyes of another Model.
+func chooseDescription(investigationUUID, criminalUUID, model string) (Description, bool, error) {
+	fallback := false
+	descriptions, err := GetDescriptionsForSuspect(criminalUUID, model, true)
+	if err != nil || len(descriptions) == 0 {
+		log.Printf("⚠️  No description of suspect %s by model %s (%v), falling back to any model.\n", criminalUUID, model, err)
+		fallback = true
+		descriptions, err = GetAnyDescriptionsForSuspect(criminalUUID)
+		if err != nil {
+			return Description{}, fallback, fmt.Errorf("could not get descriptions for suspect: %w", err)
+		}
+	}
+	if len(descriptions) == 0 {
+		return Description{}, fallback, fmt.Errorf("no description of suspect %s", criminalUUID)
+	}
+
+	x := randomForThisInvestigation(investigationUUID, len(descriptions))
+	return descriptions[x], fallback, nil
+}
+
+// Based on the UUID (of the current investigation) choose the index (of description) to be used.
+// Be consistent across the one UUID, the investigation yet choose differently on next UUID (of investigation).
+func randomForThisInvestigation(UUID string, choices int) int {
+	if choices <= 0 {
+		return 0
+	}
+	id, err := uuid.Parse(UUID)
+	if err != nil {
+		fmt.Printf("Error parsing UUID %s: %v", UUID, err)
+		return 0
+	}
+
+	pseudoRandom := binary.BigEndian.Uint64(id[0:8])
+	num := int(pseudoRandom % uint64(choices))
+
+	return num
+}
+
+// Generate answer to the question of the Round and save it. Progress is published as AnswerEvents
+// of the Round, so players can watch the reflection being written, see SubscribeAnswerEvents().
+func GenerateAnswerForRound(roundUUID, question string, description Description, model string, service Service) (Answer, error) {
+	PublishAnswerEvent(AnswerEvent{Type: EventReflectionStarted, RoundUUID: roundUUID})
+	answer, err := generateAnswer(question, description, model, service, func(token string) {
+		PublishAnswerEvent(AnswerEvent{Type: EventReflectionToken, RoundUUID: roundUUID, Text: token})
+	})
+	if err == nil {
+		answer.RoundUUID = roundUUID
+		err = SaveAnswer(answer, roundUUID)
+	}
+	if err != nil {
+		PublishAnswerEvent(AnswerEvent{Type: EventError, RoundUUID: roundUUID, Text: err.Error()})
+		return answer, err
+	}
+
+	public := answer.Public()
+	PublishAnswerEvent(AnswerEvent{Type: EventVerdict, RoundUUID: roundUUID, Answer: &public})
+	return answer, nil
 }
 
 // Generate answer to the question, based on the description of the suspect.
-// If Service defines non-empty URL, it is used as BaseURL for the OpenAI client - this allows usage of LLM proxies
-// or other services compatible with OpenAI styled API.
-// TODO: Add option to switch service (OpenAI, Anthropic, etc.)
-func GenerateAnswer(question, description, model string, service Service) (string, error) {
+// Service is called via Provider selected by its API_style, see NewProvider().
+// Returned Answer is not saved and has no RoundUUID, use SaveAnswer() for that.
+func GenerateAnswer(question string, description Description, model string, service Service) (Answer, error) {
+	return generateAnswer(question, description, model, service, nil)
+}
+
+// Generate the Answer, reflection is streamed via onReflectionToken if it is not nil.
+func generateAnswer(question string, description Description, model string, service Service, onReflectionToken func(token string)) (Answer, error) {
 	log.Printf("func GenerateAnswer() called with question: %s\n", question)
-	config := openai.DefaultConfig(service.Token)
-	if service.URL.String != "" {
-		config.BaseURL = service.URL.String
+	answer := Answer{
+		UUID:            uuid.New().String(),
+		DescriptionUUID: description.UUID,
+		Service:         service.Name,
+		Model:           model,
 	}
-	client := openai.NewClientWithConfig(config)
+	provider, err := NewProvider(service)
+	if err != nil {
+		return answer, err
+	}
+	start := time.Now()
 	const answerReflection = `ROLE: You are a player of Unusual Suspects board game - text based version. You are a witness.
 TASK: Read the description of the perpetrator and the question the police officer asked you about perpetrator.
 Write a short reflection on the perpetrator in relation to the question.
 Try to think both ways, both about the positive answer and the negative one, which one you lean more towards. Cca 100 words.
 QUESTION: %s
 DESCRIPTION OF PERPETRATOR: %s`
-	reflectionPrompt := fmt.Sprintf(answerReflection, question, description)
-	reflectionResp, err := client.CreateChatCompletion(
+	answer.ReflectionPrompt = fmt.Sprintf(answerReflection, question, description.Description)
+	reflectionResp, err := chatStream(
 		context.Background(),
-		openai.ChatCompletionRequest{
+		provider,
+		ChatRequest{
 			Model: model,
-			Messages: []openai.ChatCompletionMessage{
+			Messages: []ChatMessage{
 				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: reflectionPrompt,
+					Role: RoleUser,
+					Text: answer.ReflectionPrompt,
 				},
 			},
 		},
+		onReflectionToken,
 	)
 	if err != nil {
 		log.Printf("Error generating answer: %v\n", err)
-		return "", err
+		return answer, err
 	}
-	reflection := reflectionResp.Choices[0].Message.Content
-	log.Printf("AI sent reflection: %s\n", reflection)
-
-	const answerBoolean = `ROLE: You are a senior decision maker.
-TASK: Answer the question YES or NO. Do not write anything else. Do not write anything else. Just write YES, or NO based on the previous information.`
-	decisionResp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: reflectionPrompt,
-				},
-				{
-					Role:    openai.ChatMessageRoleAssistant,
-					Content: reflection,
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: answerBoolean,
+	answer.Reflection = reflectionResp.Text
+	log.Printf("AI sent reflection: %s\n", answer.Reflection)
+
+	// First ask for structured output, if the decision is unclear ask again with stricter prompt.
+	// Witness who does not answer clearly even then is considered to refuse the answer.
+	decisionPrompts := []struct {
+		prompt string
+		json   bool
+	}{
+		{answerJSON, true},
+		{answerStrict, false},
+	}
+	var decisionResp ChatResponse
+	for _, decision := range decisionPrompts {
+		answer.Attempts++
+		answer.DecisionPrompt = decision.prompt
+		decisionResp, err = provider.Chat(
+			context.Background(),
+			ChatRequest{
+				Model: model,
+				Messages: []ChatMessage{
+					{
+						Role: RoleUser,
+						Text: answer.ReflectionPrompt,
+					},
+					{
+						Role: RoleAssistant,
+						Text: answer.Reflection,
+					},
+					{
+						Role: RoleUser,
+						Text: answer.DecisionPrompt,
+					},
 				},
+				JSON: decision.json,
 			},
-		},
-	)
-	if err != nil {
-		log.Printf("Error generating answer: %v\n", err)
-		return "", err
+		)
+		if err != nil {
+			log.Printf("Error generating answer: %v\n", err)
+			return answer, err
+		}
+		answer.InputTokens += decisionResp.InputTokens
+		answer.OutputTokens += decisionResp.OutputTokens
+		answer.Text = decisionResp.Text
+		answer.Verdict = ParseVerdict(answer.Text)
+		if answer.Verdict == VerdictYes || answer.Verdict == VerdictNo {
+			break
+		}
+		log.Printf("Unclear decision (%s) in attempt %d: %s\n", answer.Verdict, answer.Attempts, answer.Text)
+	}
+	if answer.Verdict != VerdictYes && answer.Verdict != VerdictNo {
+		answer.Verdict = VerdictRefused
 	}
-	decision := decisionResp.Choices[0].Message.Content
-	log.Printf("AI sent decided: %s\n", reflection)
-	return decision, nil
+	answer.ProviderModel = decisionResp.Model
+	answer.InputTokens += reflectionResp.InputTokens
+	answer.OutputTokens += reflectionResp.OutputTokens
+	answer.LatencyMs = time.Since(start).Milliseconds()
+	answer.Timestamp = TimestampNow()
+	log.Printf("AI sent decided: %s (%s)\n", answer.Text, answer.Verdict)
+	return answer, nil
 }
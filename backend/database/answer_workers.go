@@ -0,0 +1,214 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Answers are generated in the background as soon as the Round is created, see NewRound().
+// Number of LLM calls running at once is bounded by the number of workers,
+// Rounds waiting for a free worker are held in the queue.
+const answerQueueSize int = 256
+
+var (
+	ErrAnswerWorkersNotStarted = errors.New("answer workers are not started")
+	ErrAnswerQueueFull         = errors.New("answer queue is full")
+	ErrAnswerTimeout           = errors.New("timed out waiting for answer")
+)
+
+type answerPool struct {
+	mu      sync.Mutex
+	queue   chan string              // UUIDs of Rounds to be answered
+	pending map[string]struct{}      // Rounds queued or being answered right now
+	flights map[string]*answerFlight // Generations in progress, shared by everyone asking for the same Round
+}
+
+// One generation of the Answer, done is closed when answer and err are set.
+type answerFlight struct {
+	done      chan struct{}
+	generated bool // Answer was newly generated, not just loaded from the database
+	answer    Answer
+	err       error
+}
+
+var answerWorkers = &answerPool{
+	pending: make(map[string]struct{}),
+	flights: make(map[string]*answerFlight),
+}
+
+// Start workers which generate the Answers for the Rounds queued by EnqueueAnswer().
+// Should be called once on the start of the server, before any Round is created.
+func StartAnswerWorkers(workers int) {
+	answerWorkers.mu.Lock()
+	defer answerWorkers.mu.Unlock()
+	if answerWorkers.queue != nil {
+		log.Println("Answer workers already started")
+		return
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	answerWorkers.queue = make(chan string, answerQueueSize)
+	for range workers {
+		go answerWorkers.work()
+	}
+	log.Printf("Started %d answer workers\n", workers)
+}
+
+// Queue generation of the Answer for the Round. Round which is already queued
+// or being answered is not queued again, so the LLM is not called twice.
+func EnqueueAnswer(roundUUID string) error {
+	return answerWorkers.enqueue(roundUUID)
+}
+
+// Is the Answer for the Round queued or being generated right now?
+func IsAnswerPending(roundUUID string) bool {
+	answerWorkers.mu.Lock()
+	defer answerWorkers.mu.Unlock()
+	_, ok := answerWorkers.pending[roundUUID]
+	return ok
+}
+
+func (p *answerPool) enqueue(roundUUID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.queue == nil {
+		return ErrAnswerWorkersNotStarted
+	}
+	if _, ok := p.pending[roundUUID]; ok {
+		return nil
+	}
+	select {
+	case p.queue <- roundUUID:
+		p.pending[roundUUID] = struct{}{}
+		return nil
+	default:
+		return ErrAnswerQueueFull
+	}
+}
+
+func (p *answerPool) work() {
+	for roundUUID := range p.queue {
+		p.answer(roundUUID)
+		p.mu.Lock()
+		delete(p.pending, roundUUID)
+		p.mu.Unlock()
+	}
+}
+
+func (p *answerPool) answer(roundUUID string) {
+	answer, err := GetOrGenerateAnswer(roundUUID)
+	if err != nil {
+		log.Printf("Could not generate answer for Round (%s): %v\n", roundUUID, err)
+		return
+	}
+	log.Printf("Answer for Round (%s): %s\n", roundUUID, answer.Verdict)
+}
+
+// Get the saved Answer of the Round or generate it, if there is none yet. Concurrent calls
+// for the same Round share one generation, so the LLM is called only once per Round.
+func GetOrGenerateAnswer(roundUUID string) (Answer, error) {
+	answer, err := GetAnswerForRound(roundUUID)
+	if err == nil {
+		return answer, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return answer, err
+	}
+	return answerWorkers.fly(roundUUID, false)
+}
+
+// Generate new Answer for the Round even if it already has one. Older Answers stay in the answers table,
+// the Round links the new one. Admin action only - players could re-roll the witness until they like the answer.
+func RegenerateAnswer(roundUUID string) (Answer, error) {
+	return answerWorkers.fly(roundUUID, true)
+}
+
+// Join the generation of the Answer for the Round which is in progress, or start a new one.
+// Unless regenerate is set, the Answer saved meanwhile by other generation is returned instead.
+func (p *answerPool) fly(roundUUID string, regenerate bool) (Answer, error) {
+	for {
+		p.mu.Lock()
+		f, ok := p.flights[roundUUID]
+		if !ok {
+			break
+		}
+		p.mu.Unlock()
+		<-f.done
+		if !regenerate || f.generated {
+			return f.answer, f.err
+		}
+		// Regeneration cannot reuse the Answer which was only loaded, wait for its own flight.
+	}
+	f := &answerFlight{done: make(chan struct{})}
+	p.flights[roundUUID] = f
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.flights, roundUUID)
+		p.mu.Unlock()
+		close(f.done)
+	}()
+
+	if !regenerate {
+		// Previous flight could have saved the Answer since GetOrGenerateAnswer() looked for it.
+		f.answer, f.err = GetAnswerForRound(roundUUID)
+		if f.err == nil {
+			return f.answer, nil
+		}
+		if !errors.Is(f.err, sql.ErrNoRows) {
+			return f.answer, f.err
+		}
+	}
+	f.generated = true
+	f.answer, f.err = AnswerRound(roundUUID)
+	return f.answer, f.err
+}
+
+// Wait until the Answer for the Round is saved and return it.
+// Returns error if the generation of the Answer failed or did not finish in time.
+// Answer is not generated here, it has to be queued by EnqueueAnswer() or generated elsewhere.
+func WaitForAnswer(roundUUID string, timeout time.Duration) (Answer, error) {
+	// Subscribe before looking into the database, so the Answer cannot slip in between.
+	events, unsubscribe := SubscribeAnswerEvents(roundUUID)
+	defer unsubscribe()
+
+	answer, err := GetAnswerForRound(roundUUID)
+	if err == nil {
+		return answer, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return answer, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return Answer{}, ErrAnswerTimeout
+		case event := <-events:
+			switch event.Type {
+			case EventVerdict:
+				return GetAnswerForRound(roundUUID)
+			case EventError:
+				return Answer{}, errors.New(event.Text)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// MARK: BIAS REPORT
+
+// What does the Model think about this face? For each Model, how often it answered YES
+// to each Question (and each Topic) about each Suspect as the criminal.
+type BiasReport struct {
+	Model            string     `json:"Model"`
+	Service          string     `json:"Service"`
+	DescriptionModel string     `json:"DescriptionModel,omitempty"` // Model whose Descriptions were answered, only in the sweep
+	Answers          int        `json:"Answers"`                    // All YES and NO answers of the Model
+	Questions        []BiasCell `json:"Questions"`                  // Suspect × Question
+	Topics           []BiasCell `json:"Topics"`                     // Suspect × Topic of the Questions
+}
+
+// YES-rate of the Model for the Suspect and the Question or Topic.
+type BiasCell struct {
+	SuspectUUID  string  `json:"SuspectUUID"`
+	QuestionUUID string  `json:"QuestionUUID,omitempty"` // Empty in BiasReport.Topics
+	Question     string  `json:"Question,omitempty"`     // English text of the Question
+	Topic        string  `json:"Topic"`
+	Answers      int     `json:"Answers"` // YES and NO answers, refused and unclear ones are not counted
+	Yes          int     `json:"Yes"`
+	YesRate      float64 `json:"YesRate"`
+	Low          float64 `json:"Low"`  // Lower bound of 95% Wilson confidence interval of YesRate
+	High         float64 `json:"High"` // Upper bound of 95% Wilson confidence interval of YesRate
+}
+
+// z-score of 95% confidence.
+const confidenceZ = 1.96
+
+// Wilson score interval of the proportion of yes in n answers, works well also for small n and rates near 0 or 1.
+func wilsonInterval(yes, n int) (float64, float64) {
+	if n == 0 {
+		return 0, 1
+	}
+	p := float64(yes) / float64(n)
+	z2 := confidenceZ * confidenceZ
+	center := (p + z2/(2*float64(n))) / (1 + z2/float64(n))
+	margin := confidenceZ * math.Sqrt(p*(1-p)/float64(n)+z2/(4*float64(n)*float64(n))) / (1 + z2/float64(n))
+	low, high := max(center-margin, 0), min(center+margin, 1)
+	if yes == 0 {
+		low = 0 // exactly, without rounding errors
+	}
+	if yes == n {
+		high = 1
+	}
+	return low, high
+}
+
+func newBiasCell(suspectUUID string, yes, answers int) BiasCell {
+	cell := BiasCell{SuspectUUID: suspectUUID, Answers: answers, Yes: yes}
+	if answers > 0 {
+		cell.YesRate = float64(yes) / float64(answers)
+	}
+	cell.Low, cell.High = wilsonInterval(yes, answers)
+	return cell
+}
+
+// Compute the BiasReport of each Model which answered the filtered Rounds, or only of StatsFilter.Model.
+// Cells with less than minAnswers answers are left out. Limit and Offset of the filter are ignored.
+func GetBiasReports(f StatsFilter, minAnswers int) ([]BiasReport, error) {
+	where, args := f.where()
+	query := fmt.Sprintf(`SELECT g.model, COALESCE(m.Service, ''), '', i.criminal_uuid, qu.UUID, qu.English, qu.Topic,
+		SUM(r.answer = '%s'), SUM(r.answer IN ('%s', '%s'))
+		%s
+		%s
+		GROUP BY g.model, i.criminal_uuid, qu.UUID
+		ORDER BY g.model, i.criminal_uuid, qu.Topic, qu.UUID`, VerdictYes, VerdictYes, VerdictNo, statsJoins, where)
+	return queryBiasReports(query, args, minAnswers)
+}
+
+// Compute the BiasReport of each Model from the answers of the offline sweep (see RunSweep()) instead of the Games.
+// Sweeps with Descriptions by different Models get separate reports. Only Model, DescriptionModel and Topic of the filter are used.
+func GetSweepBiasReports(f StatsFilter, minAnswers int) ([]BiasReport, error) {
+	conditions := []string{"1 = 1"}
+	var args []any
+	if f.Model != "" {
+		conditions = append(conditions, "sa.model = ?")
+		args = append(args, f.Model)
+	}
+	if f.DescriptionModel != "" {
+		conditions = append(conditions, "sa.description_model = ?")
+		args = append(args, f.DescriptionModel)
+	}
+	if f.Topic != "" {
+		conditions = append(conditions, "qu.Topic = ?")
+		args = append(args, f.Topic)
+	}
+	query := fmt.Sprintf(`SELECT sa.model, sa.service, sa.description_model, sa.suspect_uuid, qu.UUID, qu.English, qu.Topic,
+		SUM(sa.verdict = '%s'), SUM(sa.verdict IN ('%s', '%s'))
+		FROM sweep_answers sa
+		JOIN questions qu ON qu.UUID = sa.question_uuid
+		WHERE %s
+		GROUP BY sa.model, sa.description_model, sa.suspect_uuid, qu.UUID
+		ORDER BY sa.model, sa.description_model, sa.suspect_uuid, qu.Topic, qu.UUID`, VerdictYes, VerdictYes, VerdictNo, strings.Join(conditions, " AND "))
+	return queryBiasReports(query, args, minAnswers)
+}
+
+// Build the BiasReports from the query selecting model, service, description model, suspect UUID, question UUID,
+// question text, topic, YES answers and all YES and NO answers, ordered by model and description model.
+func queryBiasReports(query string, args []any, minAnswers int) ([]BiasReport, error) {
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []BiasReport{}
+	type topicKey struct{ suspect, topic string }
+	var topics map[topicKey]*struct{ yes, answers int }
+	var topicOrder []topicKey
+	finish := func() {
+		if len(reports) == 0 {
+			return
+		}
+		report := &reports[len(reports)-1]
+		for _, key := range topicOrder {
+			counts := topics[key]
+			if counts.answers >= max(minAnswers, 1) {
+				cell := newBiasCell(key.suspect, counts.yes, counts.answers)
+				cell.Topic = key.topic
+				report.Topics = append(report.Topics, cell)
+			}
+		}
+	}
+
+	for rows.Next() {
+		var model, service, descriptionModel, suspectUUID, questionUUID, question, topic string
+		var yes, answers int
+		if err := rows.Scan(&model, &service, &descriptionModel, &suspectUUID, &questionUUID, &question, &topic, &yes, &answers); err != nil {
+			return reports, err
+		}
+		last := len(reports) - 1
+		if last < 0 || reports[last].Model != model || reports[last].DescriptionModel != descriptionModel {
+			finish()
+			reports = append(reports, BiasReport{Model: model, Service: service, DescriptionModel: descriptionModel,
+				Questions: []BiasCell{}, Topics: []BiasCell{}})
+			topics = make(map[topicKey]*struct{ yes, answers int })
+			topicOrder = nil
+		}
+		report := &reports[len(reports)-1]
+		report.Answers += answers
+
+		key := topicKey{suspectUUID, topic}
+		if topics[key] == nil {
+			topics[key] = &struct{ yes, answers int }{}
+			topicOrder = append(topicOrder, key)
+		}
+		topics[key].yes += yes
+		topics[key].answers += answers
+
+		if answers < max(minAnswers, 1) {
+			continue
+		}
+		cell := newBiasCell(suspectUUID, yes, answers)
+		cell.QuestionUUID = questionUUID
+		cell.Question = question
+		cell.Topic = topic
+		report.Questions = append(report.Questions, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return reports, err
+	}
+	finish()
+	return reports, nil
+}
@@ -0,0 +1,49 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWilsonInterval(t *testing.T) {
+	tests := []struct {
+		name      string
+		yes, n    int
+		low, high float64
+	}{
+		{"no answers", 0, 0, 0, 1},
+		{"no YES", 0, 10, 0, 0.2775},
+		{"all YES", 10, 10, 0.7225, 1},
+		{"single YES", 1, 1, 0.2065, 1},
+		{"half YES", 5, 10, 0.2366, 0.7634},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			low, high := wilsonInterval(test.yes, test.n)
+			if math.Abs(low-test.low) > 1e-4 || math.Abs(high-test.high) > 1e-4 {
+				t.Errorf("wilsonInterval(%d, %d) = (%v, %v), want (%v, %v)", test.yes, test.n, low, high, test.low, test.high)
+			}
+		})
+	}
+
+	// Bounds at 0/n and n/n are exact, not just close.
+	for n := 1; n <= 50; n++ {
+		if low, _ := wilsonInterval(0, n); low != 0 {
+			t.Errorf("wilsonInterval(0, %d) low = %v, want exactly 0", n, low)
+		}
+		if _, high := wilsonInterval(n, n); high != 1 {
+			t.Errorf("wilsonInterval(%d, %d) high = %v, want exactly 1", n, n, high)
+		}
+	}
+}
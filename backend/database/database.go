@@ -14,13 +14,13 @@ package database
 import (
 	"database/sql"
 	"embed"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"log"
 	"math/rand/v2"
 	"os"
 	"path/filepath"
-	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
@@ -33,15 +33,19 @@ var database *sql.DB
 
 const (
 	defaultPlayerName string = "anonymous"
-	numSuspect        int    = 15 // How many suspects are in one investigation - there were 12 in original board game.
 	emoDB             string = "💾"
 )
 
+const (
+	DefaultSuspects int = 15 // How many suspects are in one investigation by default - there were 12 in original board game.
+	MinSuspects     int = 2  // Criminal and at least one innocent to eliminate.
+)
+
 // MARK: GENERAL DATABASE
 
 // Ensure that database is ready to be used. First, check if gamesDir exists, if not create it.
 // Then, check if database file exists, if not create it and initialize it.
-// Returns the database connection.
+// Finally, migrate the database schema to the latest version, see migrations.go.
 func EnsureDBAvailable(gameDBPath string) error {
 	log.Printf("%s Checking the database file at: %s\n", emoDB, gameDBPath)
 	_, err := os.Stat(gameDBPath)
@@ -79,9 +83,15 @@ func EnsureDBAvailable(gameDBPath string) error {
 	if err != nil {
 		log.Fatal(err)
 	}
-	database = db
 	log.Printf("%s Database successfully opened!", emoDB)
 
+	err = migrate(db)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	database = db
+
 	return nil
 }
 
@@ -174,7 +184,7 @@ func getSuspectsInInvestigation(suspectUUIDs []string, investigation Investigati
 
 func GetAllSuspects() ([]Suspect, error) {
 	var suspects []Suspect
-	rows, err := database.Query("SELECT uuid, image, timestamp FROM suspects", numSuspect)
+	rows, err := database.Query("SELECT uuid, image, timestamp FROM suspects")
 	if err != nil {
 		log.Printf("Could not get random suspects: %v\n", err)
 		return suspects, err
@@ -222,9 +232,16 @@ func GetSuspectsByDescriptions(limit int, serviceName, modelName string) ([]Susp
 	return suspects, nil
 }
 
-func randomSuspects() ([]Suspect, error) {
+// Source of randomness for a Game, Simulations use a seeded one instead to be reproducible.
+func newRandom() *rand.Rand {
+	return rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+}
+
+// Get count of random Suspects. Returns error if there is not enough Suspects in the database.
+// Suspects are loaded in a stable order and shuffled by random, so the seed alone decides who is drawn.
+func randomSuspects(count int, random *rand.Rand) ([]Suspect, error) {
 	var suspects []Suspect
-	rows, err := database.Query("SELECT uuid, image, timestamp FROM suspects ORDER BY RANDOM() LIMIT $1", numSuspect)
+	rows, err := database.Query("SELECT uuid, image, timestamp FROM suspects ORDER BY uuid")
 	if err != nil {
 		log.Printf("Could not get random suspects: %v\n", err)
 		return suspects, err
@@ -245,13 +262,17 @@ func randomSuspects() ([]Suspect, error) {
 		log.Printf("Error during suspects rows iteration: %v\n", err)
 		return suspects, err
 	}
+	if len(suspects) < count {
+		return nil, fmt.Errorf("not enough suspects for investigation: requested %d, available %d", count, len(suspects))
+	}
 
-	return suspects, nil
+	random.Shuffle(len(suspects), func(a, b int) { suspects[a], suspects[b] = suspects[b], suspects[a] })
+	return suspects[:count], nil
 }
 
 // MARK: PLAYER
 
-// Instance of a Player who plays the Game. Right now it can be only the Investigator.
+// Instance of a Player who plays the Game. Player is the Investigator, or the witness in ModeReverse.
 // Player UUID is generated by the frontend and stored in the browser's localStorage.
 type Player struct {
 	UUID string `json:"uuid"`
@@ -263,34 +284,61 @@ type Player struct {
 // User clicks on start and plays until they make a mistake, can be several cases. This is the Game.
 type Game struct {
 	UUID          string        `json:"uuid"`
-	Score         int           `json:"Score"`         // TODO: implement
-	Investigator  Player        `json:"Investigator"`  // The human player, right now can play only as investigator
-	Timestamp     string        `json:"Timestamp"`     // when game was created
-	Model         string        `json:"Model"`         // LLM model used for generating descriptions and answers
-	Investigation Investigation `json:"investigation"` // TODO: actually this could be Investigations []Investigation
-	Level         int           `json:"level"`         // aka number of Investigations done + 1
-	GameOver      bool          `json:"GameOver"`      // TODO: when true, Game is over
-
+	Score         int           `json:"Score"`                // Points for eliminations, see increaseScore()
+	Investigator  Player        `json:"Investigator"`         // The human player, the investigator or the witness in ModeReverse
+	Timestamp     string        `json:"Timestamp"`            // when game was created
+	Model         string        `json:"Model"`                // LLM model used for generating descriptions and answers
+	Investigation Investigation `json:"investigation"`        // The current one, the last of Investigations
+	Level         int           `json:"level"`                // aka number of Investigations done + 1
+	Status        string        `json:"Status"`               // Where in the Game we are, one of Status* constants
+	GameOver      bool          `json:"GameOver"`             // Status is StatusGameOver, criminal was released
+	Suspects      int           `json:"Suspects"`             // How many Suspects are in each Investigation of this Game
+	Mode          string        `json:"Mode"`                 // How the Game is played, see GameMode
+	Simulation    string        `json:"Simulation,omitempty"` // UUID of the Simulation which played the Game, empty for games of players
+
+	Investigations []Investigation `json:"investigations,omitempty"` // Whole history from the first one, only from GetGame()
 }
 
 // Create a new game for the current player identified by their playerUUID.
 // Multiple players can play the game at the same time, so we need to identify the player by their playerUUID.
-func NewGame(playerUUID, model string) (Game, error) {
+// Every Investigation of the Game will have the number of suspects, 0 means DefaultSuspects.
+// Empty mode means ModeClassic.
+func NewGame(playerUUID, model string, suspects int, mode string) (Game, error) {
+	return newGame(playerUUID, model, suspects, mode, "", newRandom())
+}
+
+// Create the Game, simulationUUID marks the Game played by the Simulation instead of a player.
+// Random draws the Suspects, the criminal and the Questions of the first Investigation.
+func newGame(playerUUID, model string, suspects int, mode, simulationUUID string, random *rand.Rand) (Game, error) {
 	var game Game
+	gameMode, err := GetGameMode(mode)
+	if err != nil {
+		return game, err
+	}
+	if suspects == 0 {
+		suspects = DefaultSuspects
+	}
+	if suspects < MinSuspects {
+		return game, fmt.Errorf("investigation needs at least %d suspects, got %d", MinSuspects, suspects)
+	}
 	game.UUID = uuid.New().String()
 	game.Timestamp = TimestampNow()
 	game.Score = 0
+	game.Status = gameMode.roundStartStatus()
 	game.Model = model
+	game.Suspects = suspects
+	game.Mode = gameMode.Name
+	game.Simulation = simulationUUID
 	game.Investigator = Player{
 		UUID: playerUUID,
 		Name: defaultPlayerName, // TODO: also pass from the frontend
 	}
-	err := saveGame(game)
+	err = saveGame(game)
 	if err != nil {
 		return game, err
 	}
 
-	game.Investigation, err = NewInvestigation(game.UUID)
+	game.Investigation, err = newInvestigation(game.UUID, game.Suspects, random)
 	if err != nil {
 		return game, err
 	}
@@ -306,13 +354,13 @@ func NewGame(playerUUID, model string) (Game, error) {
 // Multiple players can play the game at the same time, so we need to identify the game by playerUUID.
 func GetCurrentGame(playerUUID string) (Game, error) {
 	var game Game
-	row := database.QueryRow("SELECT uuid, timestamp, score, model FROM games WHERE player_uuid = $1 ORDER BY timestamp DESC LIMIT 1", playerUUID)
-	err := row.Scan(&game.UUID, &game.Timestamp, &game.Score, &game.Model)
+	row := database.QueryRow("SELECT uuid, timestamp, score, model, suspects, status, mode FROM games WHERE player_uuid = $1 ORDER BY timestamp DESC LIMIT 1", playerUUID)
+	err := row.Scan(&game.UUID, &game.Timestamp, &game.Score, &game.Model, &game.Suspects, &game.Status, &game.Mode)
 
 	// No game found - first play
 	if err == sql.ErrNoRows {
 		log.Println("Warning: No games in DB, creating new game")
-		return NewGame("", "") // TODO: PlayerUUID should be passed from frontend
+		return NewGame("", "", DefaultSuspects, ModeClassic) // TODO: PlayerUUID should be passed from frontend
 	}
 	if err != nil {
 		return game, err
@@ -332,13 +380,75 @@ func GetCurrentGame(playerUUID string) (Game, error) {
 		return game, err
 	}
 
-	game.GameOver = isGameOver(game)
+	game.GameOver = game.Status == StatusGameOver
+	game.Investigation.InvestigationOver = game.Status == StatusInvestigationSolved
+	if mode, err := GetGameMode(game.Mode); err == nil && mode.HumanWitness {
+		game.Investigation.revealCriminal() // witness has to see the criminal
+	}
+
+	return game, nil
+}
+
+// Get the Game with its whole history - every Investigation with Rounds, Answers and Eliminations.
+// Criminals and whole Answers are revealed only for Investigations which are over,
+// the current Investigation is shown just as the player sees it. Player UUID is left out, anyone can get the Game
+// by its UUID from the scores and the player UUID gives access to the player's Games.
+func GetGame(gameUUID string) (Game, error) {
+	var game Game
+	row := database.QueryRow(`SELECT uuid, timestamp, score, model, suspects, status, mode, COALESCE(investigator, ''), simulation_uuid
+		FROM games WHERE uuid = $1`, gameUUID)
+	err := row.Scan(&game.UUID, &game.Timestamp, &game.Score, &game.Model, &game.Suspects, &game.Status, &game.Mode, &game.Investigator.Name, &game.Simulation)
+	if err != nil {
+		return game, err
+	}
+
+	mode, err := GetGameMode(game.Mode)
+	if err != nil {
+		return game, err
+	}
+	game.Investigations, err = getInvestigations(game.UUID)
+	if err != nil {
+		return game, err
+	}
+	for x := range game.Investigations {
+		investigation := &game.Investigations[x]
+		finished, err := IsInvestigationFinished(investigation.UUID)
+		if err != nil {
+			return game, err
+		}
+		investigation.InvestigationOver = finished && !investigation.CriminalReleased
+		if finished || mode.HumanWitness {
+			investigation.revealCriminal()
+		}
+
+		for y := range investigation.Rounds {
+			round := &investigation.Rounds[y]
+			if round.AnswerUUID == "" {
+				continue
+			}
+			answer, err := GetAnswerForRound(round.UUID)
+			if err != nil {
+				return game, fmt.Errorf("could not get answer of round %s: %w", round.UUID, err)
+			}
+			if !finished {
+				answer = answer.Public()
+			}
+			round.AnswerDetails = &answer
+		}
+	}
+
+	if len(game.Investigations) > 0 {
+		game.Investigation = game.Investigations[len(game.Investigations)-1]
+	}
+	game.Level = len(game.Investigations)
+	game.GameOver = game.Status == StatusGameOver
 
 	return game, nil
 }
 
 func saveGame(game Game) error {
-	query := `INSERT INTO games (uuid, timestamp, score, investigator, player_uuid, model) VALUES (?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO games (uuid, timestamp, score, investigator, player_uuid, model, suspects, status, mode, simulation_uuid)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err := database.Exec(
 		query,
 		game.UUID,
@@ -347,179 +457,252 @@ func saveGame(game Game) error {
 		game.Investigator.Name,
 		game.Investigator.UUID,
 		game.Model,
+		game.Suspects,
+		game.Status,
+		game.Mode,
+		game.Simulation,
 	)
 	return err
 }
 
-func isGameOver(game Game) bool {
-	for x := range game.Investigation.Rounds {
-		round := game.Investigation.Rounds[x]
-		for y := range round.Eliminations {
-			elimination := round.Eliminations[y]
-			if elimination.SuspectUUID == game.Investigation.CriminalUUID {
-				return true
-			}
-		}
-	}
-	return false
-}
-
 // MARK: INVESTIGATION
 
 // Investigation is a set of X Suspects, User needs to find a Criminal among them.
 type Investigation struct {
-	UUID              string    `json:"uuid"`
-	GameUUID          string    `json:"game_uuid"`
-	Suspects          []Suspect `json:"suspects"`
-	Rounds            []Round   `json:"rounds"`            // Ordered from oldest (first) to newest (last), 1st round is [0], 2nd [1] etc.
-	CriminalUUID      string    `json:"-"`                 // Do not expose in JSON!
-	InvestigationOver bool      `json:"InvestigationOver"` // Last standing is the Criminal
-	Timestamp         string    `json:"Timestamp"`
+	UUID                string    `json:"uuid"`
+	GameUUID            string    `json:"game_uuid"`
+	Suspects            []Suspect `json:"suspects"`
+	Rounds              []Round   `json:"rounds"`              // Ordered from oldest (first) to newest (last), 1st round is [0], 2nd [1] etc.
+	CriminalUUID        string    `json:"-"`                   // Do not expose in JSON!
+	DescriptionUUID     string    `json:"-"`                   // Description of the criminal used by the witness in every Round, do not expose either!
+	DescriptionFallback bool      `json:"DescriptionFallback"` // Description is not by the Model of the Game, there was none by it
+	InvestigationOver   bool      `json:"InvestigationOver"`   // Last standing is the Criminal, Game.Status is StatusInvestigationSolved
+	Timestamp           string    `json:"Timestamp"`
+
+	Criminal         *Suspect `json:"Criminal,omitempty"` // Revealed once the Investigation is over (only from GetGame()), or to the witness in ModeReverse
+	CriminalReleased bool     `json:"CriminalReleased"`   // Criminal was eliminated, this ended the Game
+}
+
+// Point Investigation.Criminal to the criminal among its Suspects.
+func (i *Investigation) revealCriminal() {
+	for x := range i.Suspects {
+		if i.Suspects[x].UUID == i.CriminalUUID {
+			i.Criminal = &i.Suspects[x]
+		}
+	}
 }
 
+// Save the Investigation and its Suspects in order in which they are shown to the player.
 func saveInvestigation(investigation Investigation) error {
-	if len(investigation.Suspects) != 15 {
-		err := fmt.Errorf("Investigation does not have 15 suspects, has %d", (len(investigation.Suspects)))
+	if len(investigation.Suspects) < MinSuspects {
+		err := fmt.Errorf("investigation needs at least %d suspects, has %d", MinSuspects, len(investigation.Suspects))
 		log.Printf("Cannot save investigation: %v\n", err)
 		return err
 	}
 
-	query := `INSERT OR REPLACE INTO investigations
-		(uuid, game_uuid, timestamp,
-		sus1_uuid,
-		sus2_uuid,
-		sus3_uuid,
-		sus4_uuid,
-		sus5_uuid,
-		sus6_uuid,
-		sus7_uuid,
-		sus8_uuid,
-		sus9_uuid,
-		sus10_uuid,
-		sus11_uuid,
-		sus12_uuid,
-		sus13_uuid,
-		sus14_uuid,
-		sus15_uuid,
-		criminal_uuid
-		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := database.Exec(query, investigation.UUID, investigation.GameUUID, investigation.Timestamp,
-		investigation.Suspects[0].UUID,
-		investigation.Suspects[1].UUID,
-		investigation.Suspects[2].UUID,
-		investigation.Suspects[3].UUID,
-		investigation.Suspects[4].UUID,
-		investigation.Suspects[5].UUID,
-		investigation.Suspects[6].UUID,
-		investigation.Suspects[7].UUID,
-		investigation.Suspects[8].UUID,
-		investigation.Suspects[9].UUID,
-		investigation.Suspects[10].UUID,
-		investigation.Suspects[11].UUID,
-		investigation.Suspects[12].UUID,
-		investigation.Suspects[13].UUID,
-		investigation.Suspects[14].UUID,
-		investigation.CriminalUUID,
-	)
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT OR REPLACE INTO investigations (uuid, game_uuid, timestamp, criminal_uuid, description_uuid, description_fallback)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	_, err = tx.Exec(query, investigation.UUID, investigation.GameUUID, investigation.Timestamp, investigation.CriminalUUID,
+		investigation.DescriptionUUID, investigation.DescriptionFallback)
 	if err != nil {
 		log.Printf("Could not save investigation: %v", err)
 		return err
 	}
 
-	return nil
+	_, err = tx.Exec("DELETE FROM investigation_suspects WHERE investigation_uuid = ?", investigation.UUID)
+	if err != nil {
+		log.Printf("Could not clear suspects of investigation: %v", err)
+		return err
+	}
+	query = `INSERT INTO investigation_suspects (investigation_uuid, position, suspect_uuid) VALUES (?, ?, ?)`
+	for x, suspect := range investigation.Suspects {
+		_, err = tx.Exec(query, investigation.UUID, x+1, suspect.UUID)
+		if err != nil {
+			log.Printf("Could not save suspect %s of investigation: %v", suspect.UUID, err)
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-// Create a new Investigation, save it into the database and return it.
+// Create a new Investigation with number of suspects, save it into the database and return it.
 // Usage on New Game for initial first Investigation,
 // or when Investigation is successfully solved and we need new one.
-func NewInvestigation(gameUUID string) (Investigation, error) {
+func NewInvestigation(gameUUID string, suspectsCount int) (Investigation, error) {
+	return newInvestigation(gameUUID, suspectsCount, newRandom())
+}
+
+// Create the Investigation, random draws the Suspects, the criminal, the Description and the Question of the first Round.
+func newInvestigation(gameUUID string, suspectsCount int, random *rand.Rand) (Investigation, error) {
 	var i Investigation
-	i.UUID = uuid.New().String()
+	i.UUID = newInvestigationUUID(random)
 	i.GameUUID = gameUUID
 	i.Timestamp = TimestampNow()
 
-	round, err := NewRound(i.UUID)
+	suspects, err := randomSuspects(suspectsCount, random)
 	if err != nil {
 		return i, err
 	}
-	i.Rounds = append(i.Rounds, round)
+	i.Suspects = suspects
+	cn := random.IntN(len(suspects))
+	i.CriminalUUID = i.Suspects[cn].UUID
+
+	log.Printf("NEW INVESTIGATION, criminal is: no. %d of %d\n", cn+1, len(suspects))
 
-	suspects, err := randomSuspects()
+	var model, modeName string
+	err = database.QueryRow("SELECT model, mode FROM games WHERE uuid = $1", gameUUID).Scan(&model, &modeName)
+	if err != nil {
+		return i, fmt.Errorf("could not get model of game %s: %w", gameUUID, err)
+	}
+	mode, err := GetGameMode(modeName)
 	if err != nil {
 		return i, err
 	}
-	i.Suspects = suspects
-	cn := rand.IntN(len(suspects))
-	i.CriminalUUID = i.Suspects[cn].UUID
+	if mode.HumanWitness {
+		i.revealCriminal()
+	}
+	description, fallback, err := chooseDescription(i.UUID, i.CriminalUUID, model)
+	if err != nil {
+		// Not fatal, Description will be chosen again on the first Answer.
+		log.Printf("Could not choose description of criminal: %v\n", err)
+	}
+	i.DescriptionUUID = description.UUID
+	i.DescriptionFallback = fallback
 
-	log.Printf("NEW INVESTIGATION, criminal is: no. %d\n", cn+1)
 	err = saveInvestigation(i)
-	return i, err
+	if err != nil {
+		return i, err
+	}
+
+	// Round goes after the Investigation is saved, its Answer needs to know the criminal.
+	round, err := newRound(i.UUID, random)
+	if err != nil {
+		return i, err
+	}
+	i.Rounds = append(i.Rounds, round)
+	return i, nil
+}
+
+// UUID of the Investigation. Its first half chooses the Descriptions (see randomForThisInvestigation()),
+// so it is drawn from random, the rest stays random to keep the UUID unique across Simulations with the same seed.
+func newInvestigationUUID(random *rand.Rand) string {
+	id := uuid.New()
+	binary.BigEndian.PutUint64(id[0:8], random.Uint64())
+	id[6] = id[6]&0x0f | 0x40 // version 4
+	return id.String()
 }
 
 func getCurrentInvestigation(gameUUID string) (Investigation, error) {
 	var investigation = Investigation{GameUUID: gameUUID}
-	var suspects_uuids = make([]string, 15)
 	log.Printf("Getting investigation for game %s\n", gameUUID)
-	row := database.QueryRow(`SELECT uuid, timestamp, criminal_uuid,
-		sus1_uuid,
-		sus2_uuid,
-		sus3_uuid,
-		sus4_uuid,
-		sus5_uuid,
-		sus6_uuid,
-		sus7_uuid,
-		sus8_uuid,
-		sus9_uuid,
-		sus10_uuid,
-		sus11_uuid,
-		sus12_uuid,
-		sus13_uuid,
-		sus14_uuid,
-		sus15_uuid
+	row := database.QueryRow(`SELECT uuid, timestamp, criminal_uuid, description_uuid, description_fallback
 		FROM investigations WHERE game_uuid = $1 ORDER BY timestamp DESC LIMIT 1`, gameUUID)
-	err := row.Scan(&investigation.UUID, &investigation.Timestamp, &investigation.CriminalUUID,
-		&suspects_uuids[0],
-		&suspects_uuids[1],
-		&suspects_uuids[2],
-		&suspects_uuids[3],
-		&suspects_uuids[4],
-		&suspects_uuids[5],
-		&suspects_uuids[6],
-		&suspects_uuids[7],
-		&suspects_uuids[8],
-		&suspects_uuids[9],
-		&suspects_uuids[10],
-		&suspects_uuids[11],
-		&suspects_uuids[12],
-		&suspects_uuids[13],
-		&suspects_uuids[14],
-	)
+	err := row.Scan(&investigation.UUID, &investigation.Timestamp, &investigation.CriminalUUID, &investigation.DescriptionUUID, &investigation.DescriptionFallback)
 	if err != nil {
 		log.Printf("Could not get investigation: %v\n", err)
 		return investigation, err
 	}
 
+	err = loadInvestigation(&investigation)
+	return investigation, err
+}
+
+// Get all Investigations of the Game, from the first to the current one.
+func getInvestigations(gameUUID string) ([]Investigation, error) {
+	var investigations []Investigation
+	rows, err := database.Query(`SELECT uuid, timestamp, criminal_uuid, description_uuid, description_fallback
+		FROM investigations WHERE game_uuid = $1 ORDER BY timestamp ASC`, gameUUID)
+	if err != nil {
+		log.Printf("Could not get investigations: %v\n", err)
+		return investigations, err
+	}
+	for rows.Next() {
+		var investigation = Investigation{GameUUID: gameUUID}
+		err := rows.Scan(&investigation.UUID, &investigation.Timestamp, &investigation.CriminalUUID, &investigation.DescriptionUUID, &investigation.DescriptionFallback)
+		if err != nil {
+			rows.Close()
+			log.Printf("Could not scan investigation: %v\n", err)
+			return investigations, err
+		}
+		investigations = append(investigations, investigation)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return investigations, err
+	}
+
+	for x := range investigations {
+		if err := loadInvestigation(&investigations[x]); err != nil {
+			return investigations, err
+		}
+	}
+	return investigations, nil
+}
+
+// Load Rounds and Suspects of the Investigation.
+func loadInvestigation(investigation *Investigation) error {
+	var err error
 	investigation.Rounds, err = getRounds(investigation.UUID)
 	if err != nil {
-		return investigation, err
+		return err
 	}
 
-	investigation.Suspects, err = getSuspectsInInvestigation(suspects_uuids, investigation)
+	suspectUUIDs, err := getInvestigationSuspectUUIDs(investigation.UUID)
 	if err != nil {
-		return investigation, err
+		return err
+	}
+	investigation.Suspects, err = getSuspectsInInvestigation(suspectUUIDs, *investigation)
+	if err != nil {
+		return err
 	}
+	for _, suspect := range investigation.Suspects {
+		investigation.CriminalReleased = investigation.CriminalReleased || suspect.Fled
+	}
+	return nil
+}
 
-	eliminated := 0
-	for x := range investigation.Rounds {
-		eliminated += len(investigation.Rounds[x].Eliminations)
+// Investigation is finished when the Criminal fled (was released) or all innocent Suspects were released.
+func IsInvestigationFinished(investigationUUID string) (bool, error) {
+	var suspects, eliminated, criminalEliminated int
+	query := `SELECT
+		(SELECT COUNT(*) FROM investigation_suspects WHERE investigation_uuid = i.uuid),
+		(SELECT COUNT(*) FROM eliminations e JOIN rounds r ON e.RoundUUID = r.uuid WHERE r.investigation_uuid = i.uuid),
+		(SELECT COUNT(*) FROM eliminations e JOIN rounds r ON e.RoundUUID = r.uuid WHERE r.investigation_uuid = i.uuid AND e.SuspectUUID = i.criminal_uuid)
+		FROM investigations i WHERE i.uuid = $1`
+	err := database.QueryRow(query, investigationUUID).Scan(&suspects, &eliminated, &criminalEliminated)
+	if err != nil {
+		return false, fmt.Errorf("could not check if investigation %s is finished: %w", investigationUUID, err)
 	}
-	if eliminated == (numSuspect - 1) {
-		investigation.InvestigationOver = true
+	return criminalEliminated > 0 || eliminated >= suspects-1, nil
+}
+
+// Get UUIDs of the Suspects in the Investigation, in the order in which they are shown to the player.
+func getInvestigationSuspectUUIDs(investigationUUID string) ([]string, error) {
+	var suspectUUIDs []string
+	rows, err := database.Query("SELECT suspect_uuid FROM investigation_suspects WHERE investigation_uuid = $1 ORDER BY position ASC", investigationUUID)
+	if err != nil {
+		log.Printf("Could not get suspects of investigation (%s): %v\n", investigationUUID, err)
+		return suspectUUIDs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var suspectUUID string
+		if err := rows.Scan(&suspectUUID); err != nil {
+			log.Printf("Could not scan suspect of investigation (%s): %v\n", investigationUUID, err)
+			return suspectUUIDs, err
+		}
+		suspectUUIDs = append(suspectUUIDs, suspectUUID)
 	}
 
-	return investigation, nil
+	return suspectUUIDs, rows.Err()
 }
 
 // MARK: ROUND
@@ -529,40 +712,87 @@ type Round struct {
 	InvestigationUUID string        `json:"InvestigationUUID"`
 	Question          Question      `json:"Question"`
 	AnswerUUID        string        `json:"AnswerUUID"`
-	Answer            string        `json:"answer"` // TODO: Answer could be actually stored in table
+	Answer            string        `json:"answer"`                   // Copy of Answer.Verdict, whole Answer is in answers table
+	WitnessRefused    bool          `json:"WitnessRefused"`           // Witness did not answer YES or NO
+	AnswerDetails     *Answer       `json:"AnswerDetails,omitempty"`  // Whole Answer, only from GetGame()
+	QuestionOffers    []Question    `json:"QuestionOffers,omitempty"` // Questions the player chooses from in ModeChoose, see ChooseQuestion()
 	Eliminations      []Elimination `json:"Eliminations"`
 	Timestamp         string        `json:"Timestamp"`
 }
 
-func saveRound(r Round) error {
+func saveRound(q querier, r Round) error {
 	query := `
-		INSERT OR REPLACE INTO rounds (uuid, investigation_uuid, question_uuid, answer, timestamp)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT OR REPLACE INTO rounds (uuid, investigation_uuid, question_uuid, answer, answer_uuid, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?)
 		`
-	_, err := database.Exec(query, r.UUID, r.InvestigationUUID, r.Question.UUID, r.Answer, r.Timestamp)
+	_, err := q.Exec(query, r.UUID, r.InvestigationUUID, r.Question.UUID, r.Answer, r.AnswerUUID, r.Timestamp)
 	return err
 }
 
+// Create the first Round of the Investigation. Following Rounds are created by NextRound(), which checks the rules.
 func NewRound(investigationUUID string) (Round, error) {
-	var r Round
-	r.UUID = uuid.New().String()
-	r.InvestigationUUID = investigationUUID
-	r.Timestamp = TimestampNow()
-	question, err := GetRandomQuestion()
+	return newRound(investigationUUID, newRandom())
+}
+
+func newRound(investigationUUID string, random *rand.Rand) (Round, error) {
+	r, mode, err := createRound(database, investigationUUID, random)
 	if err != nil {
 		return r, err
 	}
-	r.Question = question
 
-	err = saveRound(r)
-	return r, err
+	if !mode.HumanWitness {
+		enqueueRoundAnswer(r)
+	}
+	return r, nil
+}
+
+// Create and save the Round with the Question selected for it, or with the Questions
+// offered to the player if the Game's mode lets the player choose.
+func createRound(q querier, investigationUUID string, random *rand.Rand) (Round, GameMode, error) {
+	questions, mode, err := selectQuestions(q, investigationUUID, random)
+	if err != nil {
+		return Round{}, mode, err
+	}
+	r := Round{
+		UUID:              uuid.New().String(),
+		InvestigationUUID: investigationUUID,
+		Timestamp:         TimestampNow(),
+	}
+	if mode.playerAsks() {
+		r.QuestionOffers = questions
+	} else {
+		r.Question = questions[0]
+	}
+	if err := saveRound(q, r); err != nil {
+		return r, mode, err
+	}
+	return r, mode, saveQuestionOffers(q, r.UUID, r.QuestionOffers)
+}
+
+// Start generating the Answer in the background, it is not an error if it cannot - it will be generated on request.
+// Rounds waiting for the player to choose the Question are not answered yet.
+func enqueueRoundAnswer(r Round) {
+	if r.Question.UUID == "" {
+		return
+	}
+	err := EnqueueAnswer(r.UUID)
+	if err != nil {
+		log.Printf("Answer for Round (%s) not queued, it will be generated on request: %v\n", r.UUID, err)
+	}
+}
+
+// Get UUID of the Investigation to which the Round belongs.
+func GetRoundInvestigationUUID(roundUUID string) (string, error) {
+	var investigationUUID string
+	err := database.QueryRow("SELECT investigation_uuid FROM rounds WHERE uuid = $1", roundUUID).Scan(&investigationUUID)
+	return investigationUUID, err
 }
 
 func getRounds(investigationUUID string) ([]Round, error) {
 	var rounds []Round
 	log.Println("Getting rounds for investigation", investigationUUID)
 
-	rows, err := database.Query("SELECT uuid, investigation_uuid, question_uuid, answer, timestamp FROM rounds WHERE investigation_uuid = $1 ORDER BY timestamp ASC", investigationUUID)
+	rows, err := database.Query("SELECT uuid, investigation_uuid, question_uuid, answer, answer_uuid, timestamp FROM rounds WHERE investigation_uuid = $1 ORDER BY timestamp ASC", investigationUUID)
 	if err != nil {
 		log.Printf("Could not get rounds: %v\n", err)
 		return rounds, err
@@ -571,18 +801,27 @@ func getRounds(investigationUUID string) ([]Round, error) {
 
 	for rows.Next() {
 		var round Round
-		err := rows.Scan(&round.UUID, &round.InvestigationUUID, &round.Question.UUID, &round.Answer, &round.Timestamp)
+		err := rows.Scan(&round.UUID, &round.InvestigationUUID, &round.Question.UUID, &round.Answer, &round.AnswerUUID, &round.Timestamp)
 		if err != nil {
 			log.Printf("Could not scan round: %v\n", err)
 			return rounds, err
 		}
 
-		question, err := getQuestion(round.Question.UUID)
+		round.WitnessRefused = round.Answer == VerdictRefused
+
+		if round.Question.UUID != "" {
+			round.Question, err = getQuestion(round.Question.UUID)
+			if err != nil {
+				log.Printf("Could not get question text for question_uuid=%s: %v", round.Question.UUID, err)
+				return rounds, err
+			}
+		}
+
+		round.QuestionOffers, err = getQuestionOffers(database, round.UUID)
 		if err != nil {
-			log.Printf("Could not get question text for question_uuid=%s: %v", round.Question.UUID, err)
+			log.Printf("Could not get offered Questions for Round (%s): %v\n", round.UUID, err)
 			return rounds, err
 		}
-		round.Question = question
 
 		round.Eliminations, err = getEliminationsForRound(round.UUID)
 		if err != nil {
@@ -612,36 +851,6 @@ type Elimination struct {
 	Timestamp   string `json:"Timestamp"`
 }
 
-// Save the Elimination, check if Criminal was not released
-// and if not update the Game.Score accordingly.
-func SaveElimination(suspectUUID, roundUUID, investigationUUID string) error {
-	UUID := uuid.New().String()
-	timestamp := TimestampNow()
-	query := `INSERT OR REPLACE INTO eliminations (UUID, RoundUUID, SuspectUUID, Timestamp) VALUES (?, ?, ?, ?)`
-	_, err := database.Exec(query, UUID, roundUUID, suspectUUID, timestamp)
-	if err != nil {
-		log.Printf("Could not save elimination of Suspect (%s) on Round (%s): %v\n", suspectUUID, roundUUID, err)
-		return err
-	}
-
-	var criminalUUID string
-	var gameUUID string
-	row := database.QueryRow(`SELECT criminal_uuid, game_uuid FROM investigations WHERE uuid = $1`, investigationUUID)
-	err = row.Scan(&criminalUUID, &gameUUID)
-	if err != nil {
-		log.Printf("Could not get criminal_uuid on Investigation (%s): %v\n", investigationUUID, err)
-	}
-
-	if criminalUUID != suspectUUID {
-
-		increaseScore(gameUUID, roundUUID)
-	} else {
-		log.Println("Guilty criminal was released :(")
-	}
-
-	return nil
-}
-
 func getEliminationsForRound(roundUUID string) ([]Elimination, error) {
 	var eliminations []Elimination
 	log.Printf("Getting Eliminations for Round (%s)\n", roundUUID)
@@ -677,20 +886,18 @@ func getEliminationsForRound(roundUUID string) ([]Elimination, error) {
 // MARK: QUESTION
 
 type Question struct {
-	UUID    string `json:"UUID"`
-	English string `json:"English"`
-	Czech   string `json:"Czech"`
-	Polish  string `json:"Polish"`
-	Topic   string `json:"Topic"`
-	Level   int    `json:"Level"`
+	UUID       string `json:"UUID"`
+	English    string `json:"English"`
+	Czech      string `json:"Czech"`
+	Polish     string `json:"Polish"`
+	Topic      string `json:"Topic"`
+	Level      int    `json:"Level"`
+	AuthorUUID string `json:"-"`        // Player who wrote the custom Question, must not leak - it gives access to their Games
+	Approved   bool   `json:"Approved"` // Only approved Questions are selected for Rounds, custom ones wait for ApproveQuestion()
 }
 
-func GetRandomQuestion() (Question, error) {
-	var question Question
-	row := database.QueryRow("SELECT UUID, English, Czech, Polish, Topic, Level FROM questions ORDER BY RANDOM() LIMIT 1")
-	err := row.Scan(&question.UUID, &question.English, &question.Czech, &question.Polish, &question.Topic, &question.Level)
-	return question, err
-}
+// Topic of Questions written by the players, see AskQuestion().
+const TopicCustom string = "custom"
 
 // English is the cannonical text. If question with same English version exists, it will not overwrite.
 func SaveQuestion(q Question) error {
@@ -718,8 +925,8 @@ func SaveQuestion(q Question) error {
 
 func getQuestion(questionUUID string) (Question, error) {
 	var question = Question{UUID: questionUUID}
-	row := database.QueryRow("SELECT English, Czech, Polish, Topic, Level FROM questions WHERE UUID = $1 LIMIT 1", questionUUID)
-	err := row.Scan(&question.English, &question.Czech, &question.Polish, &question.Topic, &question.Level)
+	row := database.QueryRow("SELECT English, Czech, Polish, Topic, Level, author_uuid, approved FROM questions WHERE UUID = $1 LIMIT 1", questionUUID)
+	err := row.Scan(&question.English, &question.Czech, &question.Polish, &question.Topic, &question.Level, &question.AuthorUUID, &question.Approved)
 	if err != nil {
 		log.Printf("Could not scan question (%s): %v", questionUUID, err)
 		return question, err
@@ -729,18 +936,92 @@ func getQuestion(questionUUID string) (Question, error) {
 
 // MARK: ANSWER
 
+const (
+	VerdictYes     string = "YES"
+	VerdictNo      string = "NO"
+	VerdictUnknown string = "UNKNOWN" // Model did not answer clearly YES or NO, see ParseVerdict()
+	VerdictRefused string = "REFUSED" // Witness refused to answer, or did not answer clearly even after retry
+)
+
+// Service and Model of the Answers given by the human witness in ModeReverse.
+const ServiceHuman string = "human"
+
+// Answer of the witness (AI) to the Question of the Round, together with everything
+// needed to study how it was made - the reflection is the most valuable part.
 type Answer struct {
-	UUID      string `json:"UUID"`
-	Text      string `json:"Text"`
-	Timestamp string `json:"Timestamp"`
+	UUID             string `json:"UUID"`
+	RoundUUID        string `json:"RoundUUID"`
+	DescriptionUUID  string `json:"DescriptionUUID"` // Description of the criminal the witness reasoned from
+	Reflection       string `json:"Reflection"`      // Reasoning about the question before the decision
+	Text             string `json:"Text"`            // Decision as written by the model
+	Verdict          string `json:"Verdict"`         // Text parsed to YES, NO or REFUSED
+	Attempts         int    `json:"Attempts"`        // How many times the decision was asked until it was clear
+	ReflectionPrompt string `json:"ReflectionPrompt"`
+	DecisionPrompt   string `json:"DecisionPrompt"` // Prompt of the last attempt
+	Service          string `json:"Service"`
+	Model            string `json:"Model"`         // Model which was requested to answer
+	ProviderModel    string `json:"ProviderModel"` // Model which actually answered as reported by the Service
+	LatencyMs        int64  `json:"LatencyMs"`     // How long the generation took in total
+	InputTokens      int    `json:"InputTokens"`
+	OutputTokens     int    `json:"OutputTokens"`
+	Timestamp        string `json:"Timestamp"`
 }
 
-// Save the Answer to the Round record in the database. There is then func WaitForAnswer()
-// which is called from frontend once new Round is found (and so Question can be shown ASAP).
+// Get copy of the Answer which is safe to be sent to the player during the Investigation.
+// Reflection, prompts and Description would give away more about the criminal than just YES or NO.
+func (a Answer) Public() Answer {
+	return Answer{
+		UUID:          a.UUID,
+		RoundUUID:     a.RoundUUID,
+		Text:          a.Text,
+		Verdict:       a.Verdict,
+		Attempts:      a.Attempts,
+		Service:       a.Service,
+		Model:         a.Model,
+		ProviderModel: a.ProviderModel,
+		Timestamp:     a.Timestamp,
+	}
+}
+
+// Save the Answer to the answers table and link it from the Round record in the database.
+// Answer is generated in the background once the Round is created (and so Question can be shown ASAP).
 // But Answer takes time and when it is saved here the WaitForAnswer() retrieves it later.
-func SaveAnswer(answer, roundUUID string) error {
-	query := "UPDATE rounds SET answer = $1 WHERE uuid = $2"
-	result, err := database.Exec(query, answer, roundUUID)
+func SaveAnswer(answer Answer, roundUUID string) error {
+	if answer.UUID == "" {
+		answer.UUID = uuid.New().String()
+	}
+	if answer.Timestamp == "" {
+		answer.Timestamp = TimestampNow()
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveAnswer(tx, answer, roundUUID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Save the Answer, link it from the Round and let the Game go on, see answerSaved().
+// Fails if there is no such Round, the caller's transaction must be rolled back so no orphan Answer stays.
+func saveAnswer(tx querier, answer Answer, roundUUID string) error {
+	query := `INSERT OR REPLACE INTO answers (uuid, round_uuid, description_uuid, reflection, text, verdict, attempts,
+		reflection_prompt, decision_prompt, service, model, provider_model, latency_ms, input_tokens, output_tokens, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.Exec(query, answer.UUID, roundUUID, answer.DescriptionUUID, answer.Reflection, answer.Text, answer.Verdict, answer.Attempts,
+		answer.ReflectionPrompt, answer.DecisionPrompt, answer.Service, answer.Model, answer.ProviderModel,
+		answer.LatencyMs, answer.InputTokens, answer.OutputTokens, answer.Timestamp)
+	if err != nil {
+		log.Printf("Error saving answer for round %s: %v", roundUUID, err)
+		return err
+	}
+
+	query = "UPDATE rounds SET answer = $1, answer_uuid = $2 WHERE uuid = $3"
+	result, err := tx.Exec(query, answer.Verdict, answer.UUID, roundUUID)
 	if err != nil {
 		log.Printf("Error updating answer for round %s: %v", roundUUID, err)
 		return err
@@ -753,9 +1034,26 @@ func SaveAnswer(answer, roundUUID string) error {
 	}
 	if rowsAffected == 0 {
 		log.Printf("No rows were updated for round %s", roundUUID)
+		return fmt.Errorf("could not link answer to round %s: %w", roundUUID, sql.ErrNoRows)
 	}
 
-	return nil
+	err = answerSaved(tx, roundUUID)
+	if err != nil {
+		log.Printf("Error updating game status for round %s: %v", roundUUID, err)
+	}
+	return err
+}
+
+// Get the Answer linked by the Round, that is the latest one generated for it. Returns sql.ErrNoRows if there is none yet.
+func GetAnswerForRound(roundUUID string) (Answer, error) {
+	var a Answer
+	query := `SELECT a.uuid, a.round_uuid, a.description_uuid, a.reflection, a.text, a.verdict, a.attempts,
+		a.reflection_prompt, a.decision_prompt, a.service, a.model, a.provider_model, a.latency_ms, a.input_tokens, a.output_tokens, a.timestamp
+		FROM answers a JOIN rounds r ON r.answer_uuid = a.uuid WHERE r.uuid = $1`
+	err := database.QueryRow(query, roundUUID).Scan(&a.UUID, &a.RoundUUID, &a.DescriptionUUID, &a.Reflection, &a.Text, &a.Verdict, &a.Attempts,
+		&a.ReflectionPrompt, &a.DecisionPrompt, &a.Service, &a.Model, &a.ProviderModel,
+		&a.LatencyMs, &a.InputTokens, &a.OutputTokens, &a.Timestamp)
+	return a, err
 }
 
 // MARK: LEVEL & SCORE
@@ -776,27 +1074,24 @@ func GetLevel(gameUUID string) (int, error) {
 // Amount of increase is based on in which level we are and if it is 1st, 2nd or Nth
 // Elimination in this round. Players are rewarded for risky behaviour - eliminating more than one suspect.
 // But also they are rewarded for longevity - how much investigations they have solved.
-func increaseScore(gameUUID string, roundUUID string) {
-	level, err := GetLevel(gameUUID)
+func increaseScore(q querier, gameUUID string, eliminations int) error {
+	var level int
+	err := q.QueryRow("SELECT COUNT(*) FROM investigations WHERE game_uuid = $1", gameUUID).Scan(&level)
 	if err != nil {
 		log.Println("Could not get level and increase score:", err)
-		return
-	}
-	eliminations, err := getEliminationsForRound(roundUUID)
-	if err != nil {
-		log.Println("Could not get eliminations for this round and increase score:", err)
-		return
+		return err
 	}
 
-	amount := level * len(eliminations)
+	amount := level * eliminations
 
 	query := "UPDATE games SET score = score + $1 WHERE uuid = $2"
-	_, err = database.Exec(query, amount, gameUUID)
+	_, err = q.Exec(query, amount, gameUUID)
 	if err != nil {
 		log.Printf("error increasing score for gameUUID %s: %v", gameUUID, err)
-		return
+		return err
 	}
 	fmt.Printf("Score increased by %d\n", amount)
+	return nil
 }
 
 // This is used for High Scores list.
@@ -810,7 +1105,7 @@ type FinalScore struct {
 
 func GetScores() ([]FinalScore, error) {
 	var scores []FinalScore
-	query := "SELECT uuid, score, investigator FROM games ORDER BY score DESC"
+	query := "SELECT uuid, score, investigator FROM games WHERE simulation_uuid = '' ORDER BY score DESC" // simulated games do not compete
 	rows, err := database.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get scores: %w", err)
@@ -857,7 +1152,7 @@ func SaveScore(name, gameUUID string) error {
 // Service is an LLM provider. It can be OpenAI, Anthropic, DeepSeek, or local model served via LiteLLM.
 type Service struct {
 	Name      string         `json:"Name"`      // Name presented to the user
-	API_style sql.NullString `json:"API_style"` // What is the style of the API (openai, deepseek, etc) - we can have DeepSeek provided via LiteLLM (which uses openai API style)
+	API_style sql.NullString `json:"API_style"` // What is the style of the API (openai, anthropic, ollama, gemini) - we can have DeepSeek provided via LiteLLM (which uses openai API style), see NewProvider()
 	Type      string         `json:"Type"`      // API or local
 	URL       sql.NullString `json:"URL"`
 	Token     string         `json:"Token"`
@@ -917,37 +1212,6 @@ func GetServiceForModel(modelName string) (Service, error) {
 	return service, nil
 }
 
-// Wait until non-empty Answer appears on the Round record in Rounds table.
-// Timeouts in 60 seconds, retries every 1 second. Answer is not modified anyhow,
-// needs to be handled after return. On error returned answer is "". On error during
-// generation of the answer, it is something like "failed OpenAI()". If everything was
-// ok, the answer should be YES or NO, or lowercase variant - parse it later!
-func WaitForAnswer(roundUUID string) string {
-	pollInterval := 1 * time.Second
-	timeout := 60 * time.Second
-	start := time.Now()
-	var answer string
-	for {
-		err := database.QueryRow("SELECT answer FROM rounds WHERE uuid = $1", roundUUID).Scan(&answer)
-		if err == sql.ErrNoRows {
-			log.Printf("Answer not available yet for Round (%s). Retrying...\n", roundUUID)
-		} else if err != nil {
-			log.Printf("Error querying answer for Round (%s), err: %v\n", roundUUID, err)
-			return ""
-		} else if answer == "" {
-			log.Printf("Answer is still empty, lets sleep for a while...")
-		} else {
-			log.Printf("Answer found: %s", answer)
-			return answer
-		}
-		if time.Since(start) > timeout {
-			log.Printf("timed out waiting for answer to be available on Round (%s)\n", roundUUID)
-			return ""
-		}
-		time.Sleep(pollInterval) // Wait for the polling interval before checking again
-	}
-}
-
 // MARK: AI MODELS
 
 type Model struct {
@@ -1014,24 +1278,25 @@ func GetModel(name string) (Model, error) {
 // Holds description of the Suspect image. There can be multiple descriptions for one Suspect.
 // Descriptions can be made by different Services and different Models.
 type Description struct {
-	UUID        string `json:"UUID"`
-	SuspectUUID string `json:"SuspectUUID"`
-	Service     string `json:"Service"`
-	Model       string `json:"Model"`
-	Description string `json:"Description"`
-	Prompt      string `json:"Prompt"`
-	Timestamp   string `json:"Timestamp"`
+	UUID          string `json:"UUID"`
+	SuspectUUID   string `json:"SuspectUUID"`
+	Service       string `json:"Service"`
+	Model         string `json:"Model"`         // Model which was requested to describe the image
+	ProviderModel string `json:"ProviderModel"` // Model which actually described the image as reported by the Service, empty for old descriptions
+	Description   string `json:"Description"`
+	Prompt        string `json:"Prompt"`
+	Timestamp     string `json:"Timestamp"`
 }
 
 func SaveDescription(d Description) error {
 	query := `
-		INSERT OR REPLACE INTO descriptions (UUID, SuspectUUID, Service, Model, Description, Prompt, Timestamp)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`
+		INSERT OR REPLACE INTO descriptions (UUID, SuspectUUID, Service, Model, ProviderModel, Description, Prompt, Timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
 	timestamp := TimestampNow()
 	if d.UUID == "" {
 		d.UUID = uuid.New().String()
 	}
-	_, err := database.Exec(query, d.UUID, d.SuspectUUID, d.Service, d.Model, d.Description, d.Prompt, timestamp)
+	_, err := database.Exec(query, d.UUID, d.SuspectUUID, d.Service, d.Model, d.ProviderModel, d.Description, d.Prompt, timestamp)
 	return err
 }
@@ -0,0 +1,218 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// MARK: DISAGREEMENT
+
+// Do the Models agree on what they see in a face? Item is the pair of Suspect (as the criminal) and Question
+// answered by two or more Models. Each Model rates the item by its majority answer, ties are left out.
+type DisagreementReport struct {
+	Models       []string         `json:"Models"`
+	Items        int              `json:"Items"`        // Suspect/Question pairs answered by two or more Models
+	FleissKappa  float64          `json:"FleissKappa"`  // Agreement of all Models beyond chance, 1 is full agreement
+	Disagreement float64          `json:"Disagreement"` // Share of disagreeing pairs of Models, averaged over the Items
+	Pairs        []ModelAgreement `json:"Pairs"`
+	Suspects     []ContestedItem  `json:"Suspects"`  // Most contested Suspects first
+	Questions    []ContestedItem  `json:"Questions"` // Most contested Questions first
+}
+
+// Agreement of two Models on the Items both of them answered.
+type ModelAgreement struct {
+	ModelA     string  `json:"ModelA"`
+	ModelB     string  `json:"ModelB"`
+	Items      int     `json:"Items"`
+	Agreement  float64 `json:"Agreement"`  // Share of the Items with the same answer
+	CohenKappa float64 `json:"CohenKappa"` // Agreement beyond chance
+}
+
+// Suspect or Question and how much the Models disagree about it.
+type ContestedItem struct {
+	UUID         string  `json:"UUID"`
+	Label        string  `json:"Label"`        // Image of the Suspect or English text of the Question
+	Items        int     `json:"Items"`        // Items with the Suspect or Question
+	Disagreement float64 `json:"Disagreement"` // Share of disagreeing pairs of Models, averaged over the Items
+}
+
+// Answers of the Models to one Suspect/Question pair, true is YES.
+type disagreementItem struct {
+	suspectUUID  string
+	questionUUID string
+	answers      map[string]bool
+}
+
+// Share of disagreeing pairs of the raters of the item.
+func (item disagreementItem) disagreement() float64 {
+	var yes int
+	for _, answer := range item.answers {
+		if answer {
+			yes++
+		}
+	}
+	n := len(item.answers)
+	pairs := n * (n - 1) / 2
+	return float64(yes*(n-yes)) / float64(pairs)
+}
+
+// Kappa of the observed and by-chance expected agreement. When chance agreement is full, so is the observed one.
+func kappa(observed, expected float64) float64 {
+	if expected >= 1 {
+		return 1
+	}
+	return (observed - expected) / (1 - expected)
+}
+
+// Compute the DisagreementReport from the filtered Rounds, at most StatsFilter.Limit Suspects and Questions are ranked.
+func GetDisagreementReport(f StatsFilter) (DisagreementReport, error) {
+	report := DisagreementReport{Models: []string{}, Pairs: []ModelAgreement{}, Suspects: []ContestedItem{}, Questions: []ContestedItem{}}
+	where, args := f.where()
+	query := fmt.Sprintf(`SELECT g.model, i.criminal_uuid, s.image, qu.UUID, qu.English,
+		SUM(r.answer = '%s'), SUM(r.answer = '%s')
+		%s
+		JOIN suspects s ON s.uuid = i.criminal_uuid
+		%s
+		GROUP BY g.model, i.criminal_uuid, qu.UUID`, VerdictYes, VerdictNo, statsJoins, where)
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	type itemKey struct{ suspect, question string }
+	items := make(map[itemKey]*disagreementItem)
+	labels := make(map[string]string) // UUID of Suspect or Question -> label
+	for rows.Next() {
+		var model, suspectUUID, image, questionUUID, question string
+		var yes, no int
+		if err := rows.Scan(&model, &suspectUUID, &image, &questionUUID, &question, &yes, &no); err != nil {
+			return report, err
+		}
+		if yes == no {
+			continue // no majority answer
+		}
+		key := itemKey{suspectUUID, questionUUID}
+		if items[key] == nil {
+			items[key] = &disagreementItem{suspectUUID: suspectUUID, questionUUID: questionUUID, answers: make(map[string]bool)}
+		}
+		items[key].answers[model] = yes > no
+		labels[suspectUUID] = image
+		labels[questionUUID] = question
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	var shared []disagreementItem
+	models := make(map[string]bool)
+	for _, item := range items {
+		if len(item.answers) < 2 {
+			continue
+		}
+		shared = append(shared, *item)
+		for model := range item.answers {
+			models[model] = true
+		}
+	}
+	report.Items = len(shared)
+	if report.Items == 0 {
+		return report, nil
+	}
+	for model := range models {
+		report.Models = append(report.Models, model)
+	}
+	slices.Sort(report.Models)
+
+	// Fleiss' kappa for variable number of raters: observed agreement of an item is the share of agreeing pairs.
+	var observed float64
+	var ratings, yesRatings int
+	for _, item := range shared {
+		disagreement := item.disagreement()
+		report.Disagreement += disagreement
+		observed += 1 - disagreement
+		for _, answer := range item.answers {
+			ratings++
+			if answer {
+				yesRatings++
+			}
+		}
+	}
+	report.Disagreement /= float64(len(shared))
+	observed /= float64(len(shared))
+	pYes := float64(yesRatings) / float64(ratings)
+	report.FleissKappa = kappa(observed, pYes*pYes+(1-pYes)*(1-pYes))
+
+	// Cohen's kappa for each pair of Models.
+	for a, modelA := range report.Models {
+		for _, modelB := range report.Models[a+1:] {
+			pair := ModelAgreement{ModelA: modelA, ModelB: modelB}
+			var agree, yesA, yesB int
+			for _, item := range shared {
+				answerA, okA := item.answers[modelA]
+				answerB, okB := item.answers[modelB]
+				if !okA || !okB {
+					continue
+				}
+				pair.Items++
+				if answerA == answerB {
+					agree++
+				}
+				if answerA {
+					yesA++
+				}
+				if answerB {
+					yesB++
+				}
+			}
+			if pair.Items == 0 {
+				continue
+			}
+			n := float64(pair.Items)
+			pA, pB := float64(yesA)/n, float64(yesB)/n
+			pair.Agreement = float64(agree) / n
+			pair.CohenKappa = kappa(pair.Agreement, pA*pB+(1-pA)*(1-pB))
+			report.Pairs = append(report.Pairs, pair)
+		}
+	}
+
+	limit, _ := f.page()
+	report.Suspects = rankContested(shared, labels, limit, func(item disagreementItem) string { return item.suspectUUID })
+	report.Questions = rankContested(shared, labels, limit, func(item disagreementItem) string { return item.questionUUID })
+	return report, nil
+}
+
+// Average the disagreement of the items by Suspect or Question given by key, return the limit most contested.
+func rankContested(items []disagreementItem, labels map[string]string, limit int, key func(disagreementItem) string) []ContestedItem {
+	byUUID := make(map[string]*ContestedItem)
+	for _, item := range items {
+		uuid := key(item)
+		if byUUID[uuid] == nil {
+			byUUID[uuid] = &ContestedItem{UUID: uuid, Label: labels[uuid]}
+		}
+		byUUID[uuid].Items++
+		byUUID[uuid].Disagreement += item.disagreement()
+	}
+
+	contested := []ContestedItem{}
+	for _, c := range byUUID {
+		c.Disagreement /= float64(c.Items)
+		contested = append(contested, *c)
+	}
+	slices.SortFunc(contested, func(a, b ContestedItem) int {
+		return cmp.Or(cmp.Compare(b.Disagreement, a.Disagreement), cmp.Compare(b.Items, a.Items), cmp.Compare(a.UUID, b.UUID))
+	})
+	return contested[:min(limit, len(contested))]
+}
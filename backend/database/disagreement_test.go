@@ -0,0 +1,61 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKappa(t *testing.T) {
+	tests := []struct {
+		name               string
+		observed, expected float64
+		want               float64
+	}{
+		{"full agreement", 1, 0.5, 1},
+		{"full disagreement", 0, 0.5, -1},
+		{"chance level", 0.5, 0.5, 0},
+		{"chance level, skewed answers", 0.82, 0.82, 0},
+		{"full chance agreement", 1, 1, 1},
+		{"expected rounded above 1", 1, 1 + 1e-15, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := kappa(test.observed, test.expected); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("kappa(%v, %v) = %v, want %v", test.observed, test.expected, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDisagreementItem(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers map[string]bool
+		want    float64
+	}{
+		{"full agreement on YES", map[string]bool{"a": true, "b": true, "c": true}, 0},
+		{"full agreement on NO", map[string]bool{"a": false, "b": false}, 0},
+		{"two models disagree", map[string]bool{"a": true, "b": false}, 1},
+		{"one of three disagrees", map[string]bool{"a": true, "b": true, "c": false}, 2.0 / 3},
+		{"two against two", map[string]bool{"a": true, "b": true, "c": false, "d": false}, 4.0 / 6},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := disagreementItem{answers: test.answers}
+			if got := item.disagreement(); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("disagreement() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
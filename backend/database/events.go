@@ -0,0 +1,108 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"log"
+	"sync"
+)
+
+// Types of AnswerEvent, in the order in which they come.
+const (
+	EventReflectionStarted string = "reflection_started"
+	EventReflectionToken   string = "reflection_token" // AnswerEvent.Text is the next piece of the reflection
+	EventVerdict           string = "verdict"          // AnswerEvent.Answer is ready, last event
+	EventError             string = "error"            // AnswerEvent.Text is the error message, last event
+)
+
+// Progress of the generation of the Answer for the Round.
+type AnswerEvent struct {
+	Type      string  `json:"type"`
+	RoundUUID string  `json:"round_uuid"`
+	Text      string  `json:"text,omitempty"`
+	Answer    *Answer `json:"answer,omitempty"` // Answer.Public(), only for EventVerdict
+}
+
+// Is this the last event of the generation?
+func (e AnswerEvent) Final() bool {
+	return e.Type == EventVerdict || e.Type == EventError
+}
+
+// In-process publish/subscribe of AnswerEvents, subscribers are grouped by the Round.
+// Publishing never blocks - events are dropped for subscribers which do not keep up,
+// but final events get always delivered, as subscribers wait for them.
+type answerBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan AnswerEvent]struct{}
+}
+
+const answerEventsBuffer int = 256
+
+var answerEvents = &answerBroker{subscribers: make(map[string]map[chan AnswerEvent]struct{})}
+
+// Subscribe to AnswerEvents of the Round. Call the returned function to unsubscribe.
+func SubscribeAnswerEvents(roundUUID string) (<-chan AnswerEvent, func()) {
+	return answerEvents.subscribe(roundUUID)
+}
+
+// Publish the AnswerEvent to all subscribers of its Round.
+func PublishAnswerEvent(event AnswerEvent) {
+	answerEvents.publish(event)
+}
+
+func (b *answerBroker) subscribe(roundUUID string) (<-chan AnswerEvent, func()) {
+	ch := make(chan AnswerEvent, answerEventsBuffer)
+	b.mu.Lock()
+	if b.subscribers[roundUUID] == nil {
+		b.subscribers[roundUUID] = make(map[chan AnswerEvent]struct{})
+	}
+	b.subscribers[roundUUID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[roundUUID], ch)
+			if len(b.subscribers[roundUUID]) == 0 {
+				delete(b.subscribers, roundUUID)
+			}
+			b.mu.Unlock()
+		})
+	}
+	return ch, unsubscribe
+}
+
+func (b *answerBroker) publish(event AnswerEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers[event.RoundUUID] {
+		select {
+		case ch <- event:
+			continue
+		default:
+		}
+		if !event.Final() {
+			log.Printf("Dropping %s event for slow subscriber of Round (%s)", event.Type, event.RoundUUID)
+			continue
+		}
+		// Make room for the final event by dropping the oldest one.
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
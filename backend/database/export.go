@@ -0,0 +1,256 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// MARK: EXPORT
+
+const (
+	ExportJSONL string = "jsonl"
+	ExportCSV   string = "csv"
+)
+
+// Tables of the dataset, exported with all their columns. Secrets (services) and players' data are not exported.
+// Simulations are not players, games.simulation_uuid is exported as it is.
+var exportTables = []string{"games", "investigations", "rounds", "answers", "eliminations", "descriptions", "questions", "simulations"}
+
+// Columns with player UUIDs, which are replaced by pseudonyms in the export.
+var exportPlayerColumns = map[string]bool{"player_uuid": true, "author_uuid": true}
+
+// Columns (table.column) which could identify the player, they are exported empty. Investigator of the Game
+// is the name in the scores, while investigator of the Simulation is a Model and stays.
+var exportPrivateColumns = map[string]bool{"games.investigator": true}
+
+// Denormalised file with one row per Round: the Game, Question, Answer and whether the criminal was eliminated.
+const exportRoundFacts = "round_facts"
+
+const roundFactsQuery = `SELECT r.uuid AS round_uuid, r.timestamp AS round_timestamp,
+	g.uuid AS game_uuid, g.player_uuid, g.simulation_uuid, g.simulation_uuid != '' AS is_simulated, g.model, g.mode, g.score AS game_score,
+	i.uuid AS investigation_uuid, i.criminal_uuid,
+	COALESCE(a.description_uuid, i.description_uuid) AS description_uuid,
+	r.question_uuid, COALESCE(qu.English, '') AS question, COALESCE(qu.Topic, '') AS topic, COALESCE(qu.Level, 0) AS level,
+	r.answer, COALESCE(a.provider_model, '') AS provider_model, COALESCE(a.attempts, 0) AS attempts,
+	COALESCE(a.latency_ms, 0) AS latency_ms, COALESCE(a.input_tokens, 0) AS input_tokens, COALESCE(a.output_tokens, 0) AS output_tokens,
+	(SELECT COUNT(*) FROM eliminations e WHERE e.RoundUUID = r.uuid) AS eliminations,
+	EXISTS (SELECT 1 FROM eliminations e WHERE e.RoundUUID = r.uuid AND e.SuspectUUID = i.criminal_uuid) AS criminal_eliminated
+	FROM rounds r
+	JOIN investigations i ON i.uuid = r.investigation_uuid
+	JOIN games g ON g.uuid = i.game_uuid
+	LEFT JOIN questions qu ON qu.UUID = r.question_uuid
+	LEFT JOIN answers a ON a.uuid = r.answer_uuid
+	ORDER BY g.timestamp, g.uuid, r.timestamp`
+
+// Where the files of the export are written, *zip.Writer is one. Each file is written completely before the next is created.
+type ExportTarget interface {
+	Create(name string) (io.Writer, error)
+}
+
+// ExportTarget writing the files into the directory, call Close() after the export.
+type ExportDir struct {
+	Path string
+	file *os.File
+}
+
+func (d *ExportDir) Create(name string) (io.Writer, error) {
+	if err := d.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(d.Path, os.ModePerm); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(filepath.Join(d.Path, name))
+	d.file = file
+	return file, err
+}
+
+// Close the last created file.
+func (d *ExportDir) Close() error {
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
+
+// Describes the export, saved as manifest.json next to the data files.
+type ExportManifest struct {
+	SchemaVersion int          `json:"SchemaVersion"`
+	ExportedAt    string       `json:"ExportedAt"`
+	Formats       []string     `json:"Formats"`
+	Files         []ExportFile `json:"Files"`
+}
+
+type ExportFile struct {
+	Name    string   `json:"Name"`
+	Table   string   `json:"Table"` // Table of the database, or round_facts
+	Format  string   `json:"Format"`
+	Columns []string `json:"Columns"`
+	Rows    int      `json:"Rows"`
+}
+
+// Export the dataset in the formats (ExportJSONL, ExportCSV): one file per table, round_facts and manifest.json.
+// Player UUIDs are replaced by pseudonyms - HMAC of the salt, so exports with the same salt can be joined.
+// Empty salt is replaced by a random one.
+func ExportDataset(target ExportTarget, formats []string, salt string) (ExportManifest, error) {
+	manifest := ExportManifest{ExportedAt: TimestampNow(), Formats: formats, Files: []ExportFile{}}
+	for _, format := range formats {
+		if format != ExportJSONL && format != ExportCSV {
+			return manifest, fmt.Errorf("unknown export format %q", format)
+		}
+	}
+	version, err := GetSchemaVersion()
+	if err != nil {
+		return manifest, err
+	}
+	manifest.SchemaVersion = version
+	if salt == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return manifest, err
+		}
+		salt = hex.EncodeToString(random)
+	}
+	pseudonym := func(playerUUID string) string {
+		if playerUUID == "" {
+			return ""
+		}
+		mac := hmac.New(sha256.New, []byte(salt))
+		mac.Write([]byte(playerUUID))
+		return hex.EncodeToString(mac.Sum(nil))[:32]
+	}
+
+	for _, table := range append(slices.Clone(exportTables), exportRoundFacts) {
+		query := "SELECT * FROM " + table + " ORDER BY rowid"
+		if table == exportRoundFacts {
+			query = roundFactsQuery
+		}
+		for _, format := range formats {
+			file := ExportFile{Name: table + "." + format, Table: table, Format: format}
+			w, err := target.Create(file.Name)
+			if err != nil {
+				return manifest, err
+			}
+			file.Columns, file.Rows, err = exportQuery(w, format, table, query, pseudonym)
+			if err != nil {
+				return manifest, fmt.Errorf("could not export %s: %w", file.Name, err)
+			}
+			manifest.Files = append(manifest.Files, file)
+		}
+	}
+
+	w, err := target.Create("manifest.json")
+	if err != nil {
+		return manifest, err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return manifest, encoder.Encode(manifest)
+}
+
+// Write the rows of the query of the table to w in the format, return the columns and number of rows.
+func exportQuery(w io.Writer, format, table, query string, pseudonym func(string) string) ([]string, int, error) {
+	rows, err := database.Query(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	csvWriter := csv.NewWriter(w)
+	encoder := json.NewEncoder(w)
+	if format == ExportCSV {
+		if err := csvWriter.Write(columns); err != nil {
+			return columns, 0, err
+		}
+	}
+	var count int
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for x := range values {
+		pointers[x] = &values[x]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return columns, count, err
+		}
+		for x, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[x] = string(b)
+			}
+			if s, ok := values[x].(string); ok && exportPlayerColumns[columns[x]] {
+				values[x] = pseudonym(s)
+			}
+			if exportPrivateColumns[table+"."+columns[x]] {
+				values[x] = ""
+			}
+		}
+
+		switch format {
+		case ExportJSONL:
+			object := make(map[string]any, len(columns))
+			for x, column := range columns {
+				object[column] = values[x]
+			}
+			err = encoder.Encode(object)
+		case ExportCSV:
+			record := make([]string, len(columns))
+			for x, value := range values {
+				record[x] = exportCSVValue(value)
+			}
+			err = csvWriter.Write(record)
+		}
+		if err != nil {
+			return columns, count, err
+		}
+		count++
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return columns, count, err
+	}
+	return columns, count, rows.Err()
+}
+
+func exportCSVValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MARK: AI INVESTIGATOR
+
+// In ModeReverse the human is the witness and the AI is the investigator: the Question comes from the pool,
+// the human answers it (see AnswerQuestion()) and the Model of the Game eliminates the Suspects
+// based on their Descriptions. Decisions are saved, so we can study whether models trust humans.
+type InvestigatorDecision struct {
+	UUID          string   `json:"UUID"`
+	RoundUUID     string   `json:"RoundUUID"`
+	Eliminated    []string `json:"Eliminated"` // UUIDs of the Suspects the investigator decided to eliminate
+	Reasoning     string   `json:"Reasoning"`  // Why the investigator eliminated them, as written by the model
+	Text          string   `json:"Text"`       // Whole response of the model
+	Prompt        string   `json:"Prompt"`     // Prompt of the last attempt
+	Attempts      int      `json:"Attempts"`   // How many times the decision was asked until it was clear
+	Service       string   `json:"Service"`
+	Model         string   `json:"Model"`
+	ProviderModel string   `json:"ProviderModel"`
+	LatencyMs     int64    `json:"LatencyMs"`
+	InputTokens   int      `json:"InputTokens"`
+	OutputTokens  int      `json:"OutputTokens"`
+	Timestamp     string   `json:"Timestamp"`
+}
+
+// Start of the investigator prompt, the mock recognizes it by this.
+const investigatorRole = `ROLE: You are a police investigator in Unusual Suspects board game - text based version.`
+
+const (
+	investigatorPrompt = investigatorRole + `
+TASK: You asked a witness a question about the perpetrator and got an answer. Below are descriptions of the suspects who are still left.
+Eliminate the suspects who do not match the answer of the witness - at least one. The perpetrator must not be eliminated, or you lose.
+QUESTION: %s
+ANSWER OF THE WITNESS: %s
+%s
+Respond only with JSON object {"reasoning": "short explanation", "eliminate": [numbers of the suspects to eliminate]}. Do not write anything else.`
+	investigatorStrict = `You have to decide. Reply with exactly one JSON object {"reasoning": "...", "eliminate": [numbers]},
+eliminate at least one suspect by their number. No other text.`
+)
+
+// Only one decision about the Round can be made at once, concurrent requests for it are refused.
+var investigating = struct {
+	mu     sync.Mutex
+	rounds map[string]bool
+}{rounds: make(map[string]bool)}
+
+// AI investigator eliminates Suspects of the Round based on the Answer of the human witness.
+// Decision is made only once per Round, calling again just finishes the Eliminations of the saved decision.
+func InvestigateRound(roundUUID string) (InvestigatorDecision, error) {
+	investigating.mu.Lock()
+	if investigating.rounds[roundUUID] {
+		investigating.mu.Unlock()
+		return InvestigatorDecision{}, conflict(ErrInvestigatorBusy, "investigator is already deciding round %s", roundUUID)
+	}
+	investigating.rounds[roundUUID] = true
+	investigating.mu.Unlock()
+	defer func() {
+		investigating.mu.Lock()
+		delete(investigating.rounds, roundUUID)
+		investigating.mu.Unlock()
+	}()
+
+	var investigationUUID string
+	err := database.QueryRow("SELECT investigation_uuid FROM rounds WHERE uuid = $1", roundUUID).Scan(&investigationUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return InvestigatorDecision{}, unprocessable(ErrRoundNotInInvestigation, "round %s does not exist", roundUUID)
+	}
+	if err != nil {
+		return InvestigatorDecision{}, err
+	}
+
+	decision, err := GetInvestigatorDecision(roundUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		decision, err = decideEliminations(roundUUID, investigationUUID)
+	}
+	if err != nil {
+		return decision, err
+	}
+	return decision, applyDecision(decision, investigationUUID)
+}
+
+// Ask the Model of the Game which Suspects to eliminate and save its decision.
+func decideEliminations(roundUUID, investigationUUID string) (InvestigatorDecision, error) {
+	state, err := loadInvestigationState(database, investigationUUID)
+	if err != nil {
+		return InvestigatorDecision{}, err
+	}
+	if err := state.checkInvestigator(roundUUID); err != nil {
+		return InvestigatorDecision{}, err
+	}
+	var model string
+	query := "SELECT g.model FROM investigations i JOIN games g ON g.uuid = i.game_uuid WHERE i.uuid = $1"
+	if err := database.QueryRow(query, investigationUUID).Scan(&model); err != nil {
+		return InvestigatorDecision{}, err
+	}
+
+	decision, err := askInvestigator(model, roundUUID, state)
+	if err != nil {
+		return decision, err
+	}
+	if err := saveInvestigatorDecision(decision); err != nil {
+		return decision, err
+	}
+	log.Printf("Investigator %s eliminates %d suspects in Round (%s): %s\n", model, len(decision.Eliminated), roundUUID, decision.Reasoning)
+	return decision, nil
+}
+
+// Ask the Model which of the Suspects left in the Investigation to eliminate, based on the answered Question of the Round.
+// Model sees the Suspects only through their Descriptions by the same Model, or any other if it has none.
+func askInvestigator(model, roundUUID string, state investigationState) (InvestigatorDecision, error) {
+	decision := InvestigatorDecision{UUID: uuid.New().String(), RoundUUID: roundUUID}
+	investigationUUID := state.UUID
+	var questionUUID, verdict string
+	err := database.QueryRow("SELECT question_uuid, answer FROM rounds WHERE uuid = $1", roundUUID).Scan(&questionUUID, &verdict)
+	if err != nil {
+		return decision, err
+	}
+	question, err := getQuestion(questionUUID)
+	if err != nil {
+		return decision, err
+	}
+	service, err := GetServiceForModel(model)
+	if err != nil {
+		return decision, fmt.Errorf("could not get service for model %s: %w", model, err)
+	}
+	provider, err := NewProvider(service)
+	if err != nil {
+		return decision, err
+	}
+
+	// Suspects which are left, numbered for the model in the order in which they are shown to the player.
+	suspectUUIDs, err := getInvestigationSuspectUUIDs(investigationUUID)
+	if err != nil {
+		return decision, err
+	}
+	var left []string
+	var descriptions strings.Builder
+	for _, suspectUUID := range suspectUUIDs {
+		if state.Eliminated[suspectUUID] {
+			continue
+		}
+		description, _, err := chooseDescription(investigationUUID, suspectUUID, model)
+		if err != nil {
+			return decision, err
+		}
+		left = append(left, suspectUUID)
+		fmt.Fprintf(&descriptions, "SUSPECT %d: %s\n", len(left), description.Description)
+	}
+
+	decision.Service = service.Name
+	decision.Model = model
+	start := time.Now()
+	messages := []ChatMessage{{Role: RoleUser, Text: fmt.Sprintf(investigatorPrompt, question.English, verdict, descriptions.String())}}
+	for _, prompt := range []string{messages[0].Text, investigatorStrict} {
+		if decision.Attempts > 0 {
+			messages = append(messages, ChatMessage{Role: RoleUser, Text: prompt})
+		}
+		decision.Attempts++
+		decision.Prompt = prompt
+		resp, err := provider.Chat(context.Background(), ChatRequest{Model: model, Messages: messages, JSON: true})
+		if err != nil {
+			return decision, err
+		}
+		decision.ProviderModel = resp.Model
+		decision.InputTokens += resp.InputTokens
+		decision.OutputTokens += resp.OutputTokens
+		decision.Text = resp.Text
+		messages = append(messages, ChatMessage{Role: RoleAssistant, Text: resp.Text})
+
+		var numbers []int
+		decision.Reasoning, numbers = parseInvestigatorDecision(resp.Text)
+		decision.Eliminated = nil
+		for _, n := range numbers {
+			if n >= 1 && n <= len(left) && !slices.Contains(decision.Eliminated, left[n-1]) {
+				decision.Eliminated = append(decision.Eliminated, left[n-1])
+			}
+		}
+		if len(decision.Eliminated) > 0 {
+			break
+		}
+		log.Printf("Unclear investigator decision in attempt %d: %s\n", decision.Attempts, resp.Text)
+	}
+	if len(decision.Eliminated) == 0 {
+		return decision, fmt.Errorf("investigator %s did not eliminate anybody in round %s", model, roundUUID)
+	}
+	decision.LatencyMs = time.Since(start).Milliseconds()
+	decision.Timestamp = TimestampNow()
+	return decision, nil
+}
+
+// Get the reasoning and numbers of Suspects to eliminate from JSON like {"reasoning": "...", "eliminate": [1, 3]}.
+func parseInvestigatorDecision(text string) (string, []int) {
+	var decision struct {
+		Reasoning string `json:"reasoning"`
+		Eliminate []int  `json:"eliminate"`
+	}
+	object := jsonObjectRegexp.FindString(text)
+	if object == "" || json.Unmarshal([]byte(object), &decision) != nil {
+		return "", nil
+	}
+	return decision.Reasoning, decision.Eliminate
+}
+
+// Eliminate the Suspects of the decision which are not eliminated yet. All of them are eliminated at once,
+// so if the criminal is among them, they go first - investigator who eliminates the criminal loses.
+func applyDecision(decision InvestigatorDecision, investigationUUID string) error {
+	var criminalUUID string
+	err := database.QueryRow("SELECT criminal_uuid FROM investigations WHERE uuid = $1", investigationUUID).Scan(&criminalUUID)
+	if err != nil {
+		return err
+	}
+	eliminated := slices.Clone(decision.Eliminated)
+	slices.SortStableFunc(eliminated, func(a, b string) int {
+		if a == criminalUUID {
+			return -1
+		}
+		if b == criminalUUID {
+			return 1
+		}
+		return 0
+	})
+
+	for _, suspectUUID := range eliminated {
+		err := saveElimination(suspectUUID, decision.RoundUUID, investigationUUID, false)
+		var ruleErr *RuleError
+		if errors.As(err, &ruleErr) {
+			switch ruleErr.Code {
+			case ErrSuspectEliminated:
+				continue // eliminated by previous call
+			case ErrGameOver, ErrInvestigationOver:
+				return nil // nobody else can be eliminated
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveInvestigatorDecision(d InvestigatorDecision) error {
+	eliminated, err := json.Marshal(d.Eliminated)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO investigator_decisions (uuid, round_uuid, eliminated, reasoning, text, prompt, attempts,
+		service, model, provider_model, latency_ms, input_tokens, output_tokens, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = database.Exec(query, d.UUID, d.RoundUUID, string(eliminated), d.Reasoning, d.Text, d.Prompt, d.Attempts,
+		d.Service, d.Model, d.ProviderModel, d.LatencyMs, d.InputTokens, d.OutputTokens, d.Timestamp)
+	return err
+}
+
+// Get the decision of the AI investigator about the Round, sql.ErrNoRows if there is none yet.
+func GetInvestigatorDecision(roundUUID string) (InvestigatorDecision, error) {
+	var d InvestigatorDecision
+	var eliminated string
+	query := `SELECT uuid, round_uuid, eliminated, reasoning, text, prompt, attempts, service, model, provider_model,
+		latency_ms, input_tokens, output_tokens, timestamp FROM investigator_decisions WHERE round_uuid = $1`
+	err := database.QueryRow(query, roundUUID).Scan(&d.UUID, &d.RoundUUID, &eliminated, &d.Reasoning, &d.Text, &d.Prompt, &d.Attempts,
+		&d.Service, &d.Model, &d.ProviderModel, &d.LatencyMs, &d.InputTokens, &d.OutputTokens, &d.Timestamp)
+	if err != nil {
+		return d, err
+	}
+	return d, json.Unmarshal([]byte(eliminated), &d.Eliminated)
+}
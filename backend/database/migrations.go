@@ -0,0 +1,609 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Migration is one ordered step of the database schema.
+// Version must be unique and migrations are applied in ascending Version order.
+// Once a Migration is released, it must never be changed - add a new one instead.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// All known schema migrations, ordered from the oldest to the newest.
+// The embedded default.db is at version 0, so fresh and existing databases go through the same steps.
+var migrations = []Migration{
+	{Version: 1, Name: "baseline tables", Up: migrateBaseline},
+	{Version: 2, Name: "games player_uuid and model", Up: migrateGamesPlayerModel},
+	{Version: 3, Name: "models Visual, Allowed, Historical, Price and Weight", Up: migrateModelsFlags},
+	{Version: 4, Name: "services API_style", Up: migrateServicesAPIStyle},
+	{Version: 5, Name: "services API_style defaults", Up: migrateServicesAPIStyleDefaults},
+	{Version: 6, Name: "mock service and model", Up: migrateMockService},
+	{Version: 7, Name: "descriptions ProviderModel", Up: migrateDescriptionsProviderModel},
+	{Version: 8, Name: "investigation_suspects and games suspects", Up: migrateInvestigationSuspects},
+	{Version: 9, Name: "answers", Up: migrateAnswers},
+	{Version: 10, Name: "answers attempts", Up: migrateAnswersAttempts},
+	{Version: 11, Name: "games status", Up: migrateGamesStatus},
+	{Version: 12, Name: "investigations description", Up: migrateInvestigationsDescription},
+	{Version: 13, Name: "games mode", Up: migrateGamesMode},
+	{Version: 14, Name: "question_offers", Up: migrateQuestionOffers},
+	{Version: 15, Name: "questions author_uuid and approved", Up: migrateQuestionsCustom},
+	{Version: 16, Name: "investigator_decisions", Up: migrateInvestigatorDecisions},
+	{Version: 17, Name: "simulations", Up: migrateSimulations},
+	{Version: 18, Name: "sweep_answers", Up: migrateSweepAnswers},
+	{Version: 19, Name: "games simulation_uuid", Up: migrateGamesSimulation},
+	{Version: 20, Name: "sweep_answers description_model", Up: migrateSweepDescriptionModel},
+}
+
+// Latest schema version this build of the program understands.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Get the schema version of the database, 0 means no migration has been applied yet.
+func GetSchemaVersion() (int, error) {
+	return schemaVersion(database)
+}
+
+func schemaVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("could not read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// Bring the schema of the database up to LatestSchemaVersion().
+// Each Migration runs in its own transaction together with the record of its Version,
+// so interrupted run can be safely restarted. Refuses to touch database which has
+// newer schema version than this program knows - it was created by newer release.
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT,
+		timestamp TEXT
+	)`)
+	if err != nil {
+		return fmt.Errorf("could not create schema_migrations table: %w", err)
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than the latest supported version %d, please upgrade the program", current, latest)
+	}
+	if current == latest {
+		log.Printf("%s Database schema is up to date (version %d)", emoDB, current)
+		return nil
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		log.Printf("%s Migrating database schema to version %d: %s", emoDB, m.Version, m.Name)
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+	}
+	log.Printf("%s Database schema migrated from version %d to %d", emoDB, current, latest)
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO schema_migrations (version, name, timestamp) VALUES (?, ?, ?)"
+	_, err = tx.Exec(query, m.Version, m.Name, TimestampNow())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Add column to the table if it does not exist yet.
+// Databases in the wild were sometimes edited by hand, so the column might be already there.
+// Returns true if the column was added.
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) (bool, error) {
+	exists, err := columnExists(tx, table, column)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return false, fmt.Errorf("could not add column %s.%s: %w", table, column, err)
+	}
+	return true, nil
+}
+
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("could not get columns of table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// MARK: MIGRATIONS
+
+// Tables as they are in the embedded default.db, so databases created from scratch end up the same.
+func migrateBaseline(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS games (
+			uuid TEXT PRIMARY KEY,
+			score INT,
+			investigator TEXT,
+			timestamp TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS investigations (
+			uuid TEXT PRIMARY KEY,
+			game_uuid TEXT,
+			timestamp TEXT,
+			criminal_uuid TEXT,
+			sus1_uuid TEXT,
+			sus2_uuid TEXT,
+			sus3_uuid TEXT,
+			sus4_uuid TEXT,
+			sus5_uuid TEXT,
+			sus6_uuid TEXT,
+			sus7_uuid TEXT,
+			sus8_uuid TEXT,
+			sus9_uuid TEXT,
+			sus10_uuid TEXT,
+			sus11_uuid TEXT,
+			sus12_uuid TEXT,
+			sus13_uuid TEXT,
+			sus14_uuid TEXT,
+			sus15_uuid TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS rounds (
+			uuid TEXT PRIMARY KEY,
+			investigation_uuid TEXT,
+			question_uuid TEXT,
+			answer TEXT,
+			timestamp TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS eliminations (
+			UUID TEXT PRIMARY KEY,
+			RoundUUID TEXT,
+			SuspectUUID TEXT,
+			Timestamp TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS descriptions (
+			UUID TEXT PRIMARY KEY,
+			SuspectUUID TEXT,
+			Service TEXT,
+			Model TEXT,
+			Description TEXT,
+			Prompt TEXT,
+			Timestamp TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS questions (
+			UUID TEXT PRIMARY KEY,
+			English TEXT,
+			Czech TEXT,
+			Polish TEXT,
+			Topic TEXT,
+			Level INT
+		)`,
+		`CREATE TABLE IF NOT EXISTS suspects (
+			uuid TEXT PRIMARY KEY,
+			image TEXT,
+			timestamp TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS models (
+			Name TEXT PRIMARY KEY,
+			Service TEXT,
+			Active INT
+		)`,
+		`CREATE TABLE IF NOT EXISTS services (
+			Name TEXT PRIMARY KEY,
+			Type TEXT,
+			TextModel TEXT,
+			VisualModel TEXT,
+			Token TEXT,
+			URL TEXT,
+			Active INTEGER
+		)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Games are looked up by the player and remember the Model they are played with.
+func migrateGamesPlayerModel(tx *sql.Tx) error {
+	if _, err := addColumnIfMissing(tx, "games", "player_uuid", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(tx, "games", "model", "TEXT"); err != nil {
+		return err
+	}
+	_, err := tx.Exec("CREATE INDEX IF NOT EXISTS games_player_uuid ON games (player_uuid, timestamp)")
+	return err
+}
+
+// Models got flags for the frontend and ordering. Allowed is taken over from the old Active column.
+func migrateModelsFlags(tx *sql.Tx) error {
+	added, err := addColumnIfMissing(tx, "models", "Visual", "INT NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	if added { // all models shipped in default.db can see images
+		_, err := tx.Exec("UPDATE models SET Visual = 1 WHERE Name LIKE 'gpt-4o%' OR Name LIKE 'chatgpt-4o%' OR Name LIKE 'claude-3%'")
+		if err != nil {
+			return err
+		}
+	}
+	added, err = addColumnIfMissing(tx, "models", "Allowed", "INT NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	if added {
+		if _, err := tx.Exec("UPDATE models SET Allowed = COALESCE(Active, 0)"); err != nil {
+			return err
+		}
+	}
+	if _, err := addColumnIfMissing(tx, "models", "Historical", "INT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(tx, "models", "Price", "REAL NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err = addColumnIfMissing(tx, "models", "Weight", "INT NOT NULL DEFAULT 0")
+	return err
+}
+
+// Services tell in which style their API talks, see Service.API_style.
+func migrateServicesAPIStyle(tx *sql.Tx) error {
+	_, err := addColumnIfMissing(tx, "services", "API_style", "TEXT")
+	return err
+}
+
+// Services shipped in default.db get their API_style, so they are called via the right Provider.
+func migrateServicesAPIStyleDefaults(tx *sql.Tx) error {
+	styles := map[string]string{
+		"OpenAI":    APIStyleOpenAI,
+		"DeepSeek":  APIStyleDeepSeek,
+		"Anthropic": APIStyleAnthropic,
+		"Ollama":    APIStyleOllama,
+		"Gemini":    APIStyleGemini,
+	}
+	for name, style := range styles {
+		query := "UPDATE services SET API_style = ? WHERE Name = ? AND (API_style IS NULL OR API_style = '')"
+		if _, err := tx.Exec(query, style, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Built-in Mock service for offline play and CI. It is not Allowed, so players do not see it
+// unless the operator allows it in the models table.
+func migrateMockService(tx *sql.Tx) error {
+	query := `INSERT OR IGNORE INTO services (Name, Type, Token, URL, Active, API_style)
+		VALUES ('Mock', 'local', '', 'mock://?latency=2s', 1, ?)`
+	if _, err := tx.Exec(query, APIStyleMock); err != nil {
+		return err
+	}
+	query = `INSERT OR IGNORE INTO models (Name, Service, Active, Visual, Allowed, Historical)
+		VALUES ('mock', 'Mock', 0, 1, 0, 0)`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// Descriptions remember which model really answered, Services can silently route to a different one.
+func migrateDescriptionsProviderModel(tx *sql.Tx) error {
+	_, err := addColumnIfMissing(tx, "descriptions", "ProviderModel", "TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+// Suspects of the Investigation get their own table instead of sus1_uuid...sus15_uuid columns,
+// so Investigation can have any number of them. Games remember how many suspects they use.
+func migrateInvestigationSuspects(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS investigation_suspects (
+		investigation_uuid TEXT NOT NULL,
+		position INT NOT NULL,
+		suspect_uuid TEXT NOT NULL,
+		PRIMARY KEY (investigation_uuid, position)
+	)`)
+	if err != nil {
+		return err
+	}
+
+	for position := 1; position <= 15; position++ {
+		column := fmt.Sprintf("sus%d_uuid", position)
+		exists, err := columnExists(tx, "investigations", column)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		query := fmt.Sprintf(`INSERT OR IGNORE INTO investigation_suspects (investigation_uuid, position, suspect_uuid)
+			SELECT uuid, %d, %s FROM investigations WHERE %s IS NOT NULL AND %s != ''`, position, column, column, column)
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE investigations DROP COLUMN %s", column)); err != nil {
+			return err
+		}
+	}
+
+	_, err = addColumnIfMissing(tx, "games", "suspects", "INT NOT NULL DEFAULT 15") // all older games had 15
+	return err
+}
+
+// Answers get their own table with reflection, prompts and usage. Rounds link to their Answer.
+func migrateAnswers(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS answers (
+		uuid TEXT PRIMARY KEY,
+		round_uuid TEXT NOT NULL,
+		description_uuid TEXT NOT NULL DEFAULT '',
+		reflection TEXT NOT NULL DEFAULT '',
+		text TEXT NOT NULL DEFAULT '',
+		verdict TEXT NOT NULL DEFAULT '',
+		reflection_prompt TEXT NOT NULL DEFAULT '',
+		decision_prompt TEXT NOT NULL DEFAULT '',
+		service TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL DEFAULT '',
+		provider_model TEXT NOT NULL DEFAULT '',
+		latency_ms INT NOT NULL DEFAULT 0,
+		input_tokens INT NOT NULL DEFAULT 0,
+		output_tokens INT NOT NULL DEFAULT 0,
+		timestamp TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("CREATE INDEX IF NOT EXISTS answers_round_uuid ON answers (round_uuid)"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE rounds SET answer = '' WHERE answer IS NULL"); err != nil {
+		return err
+	}
+	_, err = addColumnIfMissing(tx, "rounds", "answer_uuid", "TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+// Answers remember how many times the decision was asked, unclear decisions are retried.
+func migrateAnswersAttempts(tx *sql.Tx) error {
+	_, err := addColumnIfMissing(tx, "answers", "attempts", "INT NOT NULL DEFAULT 1")
+	return err
+}
+
+// Games store their status instead of computing it from eliminations every time.
+// Status of existing Games is derived from the last Round of their current Investigation.
+func migrateGamesStatus(tx *sql.Tx) error {
+	added, err := addColumnIfMissing(tx, "games", "status", "TEXT NOT NULL DEFAULT 'awaiting_answer'")
+	if err != nil || !added {
+		return err
+	}
+
+	current := `WITH current AS (
+		SELECT i.uuid, i.game_uuid, i.criminal_uuid FROM investigations i
+		WHERE i.uuid = (SELECT uuid FROM investigations WHERE game_uuid = i.game_uuid ORDER BY timestamp DESC LIMIT 1)
+	) `
+	statements := []string{
+		current + `UPDATE games SET status = 'awaiting_elimination' WHERE uuid IN (
+			SELECT c.game_uuid FROM current c
+			WHERE (SELECT answer FROM rounds WHERE investigation_uuid = c.uuid ORDER BY timestamp DESC LIMIT 1) != ''
+		)`,
+		current + `UPDATE games SET status = 'investigation_solved' WHERE uuid IN (
+			SELECT c.game_uuid FROM current c
+			WHERE (SELECT COUNT(DISTINCT e.SuspectUUID) FROM eliminations e JOIN rounds r ON r.uuid = e.RoundUUID WHERE r.investigation_uuid = c.uuid)
+				>= (SELECT COUNT(*) FROM investigation_suspects WHERE investigation_uuid = c.uuid) - 1
+		)`,
+		current + `UPDATE games SET status = 'game_over' WHERE uuid IN (
+			SELECT c.game_uuid FROM current c
+			WHERE EXISTS (SELECT 1 FROM eliminations e JOIN rounds r ON r.uuid = e.RoundUUID
+				WHERE r.investigation_uuid = c.uuid AND e.SuspectUUID = c.criminal_uuid)
+		)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Investigations remember the Description of the criminal the witness uses, so it does not change
+// when new Descriptions are added. Existing Investigations take the one their Answers were based on.
+func migrateInvestigationsDescription(tx *sql.Tx) error {
+	if _, err := addColumnIfMissing(tx, "investigations", "description_uuid", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	added, err := addColumnIfMissing(tx, "investigations", "description_fallback", "INT NOT NULL DEFAULT 0")
+	if err != nil || !added {
+		return err
+	}
+
+	statements := []string{
+		`UPDATE investigations SET description_uuid = COALESCE((
+			SELECT a.description_uuid FROM answers a JOIN rounds r ON r.uuid = a.round_uuid
+			WHERE r.investigation_uuid = investigations.uuid AND a.description_uuid != ''
+			ORDER BY a.timestamp ASC LIMIT 1
+		), '')`,
+		`UPDATE investigations SET description_fallback = 1 WHERE description_uuid != '' AND (
+			SELECT d.Model FROM descriptions d WHERE d.UUID = investigations.description_uuid
+		) IS NOT (
+			SELECT g.model FROM games g WHERE g.uuid = investigations.game_uuid
+		)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Games have a mode which decides how they are played, e.g. how Questions are selected.
+func migrateGamesMode(tx *sql.Tx) error {
+	_, err := addColumnIfMissing(tx, "games", "mode", "TEXT NOT NULL DEFAULT 'classic'")
+	return err
+}
+
+// Questions offered to the player in ModeChoose and which one they chose.
+// Rounds with offers have empty question_uuid until the player chooses.
+func migrateQuestionOffers(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS question_offers (
+		round_uuid TEXT NOT NULL,
+		position INT NOT NULL,
+		question_uuid TEXT NOT NULL,
+		chosen INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (round_uuid, position)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS question_offers_question_uuid ON question_offers (question_uuid)")
+	return err
+}
+
+// Players can write custom Questions, they stay out of the random pool until approved.
+// Existing Questions come from the game designers, so they are approved.
+func migrateQuestionsCustom(tx *sql.Tx) error {
+	if _, err := addColumnIfMissing(tx, "questions", "author_uuid", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	_, err := addColumnIfMissing(tx, "questions", "approved", "INT NOT NULL DEFAULT 1")
+	return err
+}
+
+// Decisions of the AI investigator in ModeReverse, which Suspects it eliminated and why.
+func migrateInvestigatorDecisions(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS investigator_decisions (
+		uuid TEXT PRIMARY KEY,
+		round_uuid TEXT NOT NULL UNIQUE,
+		eliminated TEXT NOT NULL DEFAULT '[]',
+		reasoning TEXT NOT NULL DEFAULT '',
+		text TEXT NOT NULL DEFAULT '',
+		prompt TEXT NOT NULL DEFAULT '',
+		attempts INT NOT NULL DEFAULT 0,
+		service TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL DEFAULT '',
+		provider_model TEXT NOT NULL DEFAULT '',
+		latency_ms INT NOT NULL DEFAULT 0,
+		input_tokens INT NOT NULL DEFAULT 0,
+		output_tokens INT NOT NULL DEFAULT 0,
+		timestamp TEXT NOT NULL
+	)`)
+	return err
+}
+
+// Self-play Simulations, their Games have the Simulation's uuid as player_uuid.
+func migrateSimulations(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS simulations (
+		uuid TEXT PRIMARY KEY,
+		witness TEXT NOT NULL,
+		investigator TEXT NOT NULL,
+		seed INT NOT NULL DEFAULT 0,
+		games INT NOT NULL DEFAULT 0,
+		suspects INT NOT NULL DEFAULT 0,
+		max_investigations INT NOT NULL DEFAULT 0,
+		timestamp TEXT NOT NULL
+	)`)
+	return err
+}
+
+// Answers of the offline sweep, one per model, description and question, see RunSweep().
+func migrateSweepAnswers(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS sweep_answers (
+		uuid TEXT PRIMARY KEY,
+		suspect_uuid TEXT NOT NULL,
+		description_uuid TEXT NOT NULL,
+		question_uuid TEXT NOT NULL,
+		reflection TEXT NOT NULL DEFAULT '',
+		text TEXT NOT NULL DEFAULT '',
+		verdict TEXT NOT NULL DEFAULT '',
+		attempts INT NOT NULL DEFAULT 0,
+		reflection_prompt TEXT NOT NULL DEFAULT '',
+		decision_prompt TEXT NOT NULL DEFAULT '',
+		service TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL,
+		provider_model TEXT NOT NULL DEFAULT '',
+		latency_ms INT NOT NULL DEFAULT 0,
+		input_tokens INT NOT NULL DEFAULT 0,
+		output_tokens INT NOT NULL DEFAULT 0,
+		timestamp TEXT NOT NULL,
+		UNIQUE (model, description_uuid, question_uuid)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS sweep_answers_suspect_question ON sweep_answers (suspect_uuid, question_uuid)")
+	return err
+}
+
+// Simulated Games are marked by their Simulation, before they had it as player_uuid.
+func migrateGamesSimulation(tx *sql.Tx) error {
+	if _, err := addColumnIfMissing(tx, "games", "simulation_uuid", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE games SET simulation_uuid = player_uuid, player_uuid = ''
+		WHERE player_uuid IN (SELECT uuid FROM simulations)`)
+	return err
+}
+
+// Sweeps of one Model with Descriptions by different Models are told apart by the Model of the Descriptions.
+func migrateSweepDescriptionModel(tx *sql.Tx) error {
+	if _, err := addColumnIfMissing(tx, "sweep_answers", "description_model", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE sweep_answers SET description_model =
+		COALESCE((SELECT d.Model FROM descriptions d WHERE d.UUID = sweep_answers.description_uuid), '')`)
+	return err
+}
@@ -0,0 +1,217 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	anthropicDefaultURL string = "https://api.anthropic.com"
+	anthropicVersion    string = "2023-06-01"
+	anthropicMaxTokens  int    = 2048 // Messages API requires max_tokens, descriptions are up to 800 words
+)
+
+// Provider for Anthropic Messages API.
+type anthropicProvider struct {
+	baseURL string
+	token   string
+}
+
+func newAnthropicProvider(service Service) (*anthropicProvider, error) {
+	if service.Token == "" {
+		return nil, fmt.Errorf("token for service %s not set", service.Name)
+	}
+	return &anthropicProvider{
+		baseURL: serviceBaseURL(service, anthropicDefaultURL),
+		token:   service.Token,
+	}, nil
+}
+
+type anthropicContent struct {
+	Type   string           `json:"type"`
+	Text   string           `json:"text,omitempty"`
+	Source *anthropicSource `json:"source,omitempty"`
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	Messages  []anthropicMessage `json:"messages"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Model   string             `json:"model"`
+	Content []anthropicContent `json:"content"`
+	Usage   struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// Build the request body. Messages API has no JSON mode, but the response can be prefilled
+// to start the JSON object - returned prefill must be prepended to the response text.
+func (p *anthropicProvider) request(req ChatRequest) (anthropicRequest, string) {
+	body := anthropicRequest{
+		Model:     req.Model,
+		MaxTokens: anthropicMaxTokens,
+	}
+	for _, m := range req.Messages {
+		body.Messages = append(body.Messages, toAnthropicMessage(m))
+	}
+	prefill := ""
+	if req.JSON {
+		prefill = "{"
+		body.Messages = append(body.Messages, anthropicMessage{
+			Role:    RoleAssistant,
+			Content: []anthropicContent{{Type: "text", Text: prefill}},
+		})
+	}
+	return body, prefill
+}
+
+func (p *anthropicProvider) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         p.token,
+		"anthropic-version": anthropicVersion,
+	}
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	body, prefill := p.request(req)
+	var resp anthropicResponse
+	err := postJSON(ctx, p.baseURL+"/v1/messages", p.headers(), body, &resp)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("anthropic: %w", err)
+	}
+
+	var text strings.Builder
+	text.WriteString(prefill)
+	for _, c := range resp.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	if text.Len() == len(prefill) {
+		return ChatResponse{}, errors.New("anthropic returned no text content")
+	}
+
+	return ChatResponse{
+		Text:         text.String(),
+		Model:        resp.Model,
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+	}, nil
+}
+
+// One of the Server-Sent Events of streamed Messages API response, only the fields we need.
+type anthropicStreamEvent struct {
+	Type    string             `json:"type"`
+	Message *anthropicResponse `json:"message"` // message_start
+	Delta   struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"` // content_block_delta
+	Usage *struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"` // message_delta
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *anthropicProvider) ChatStream(ctx context.Context, req ChatRequest, onToken func(token string)) (ChatResponse, error) {
+	body, prefill := p.request(req)
+	body.Stream = true
+
+	var result ChatResponse
+	var text strings.Builder
+	text.WriteString(prefill)
+	if prefill != "" {
+		onToken(prefill)
+	}
+	err := postStream(ctx, p.baseURL+"/v1/messages", p.headers(), body, func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var event anthropicStreamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("could not unmarshal stream event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				result.Model = event.Message.Model
+				result.InputTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				text.WriteString(event.Delta.Text)
+				onToken(event.Delta.Text)
+			}
+		case "message_delta":
+			if event.Usage != nil {
+				result.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return errors.New(event.Error.Message)
+			}
+			return errors.New("unknown stream error")
+		}
+		return nil
+	})
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("anthropic: %w", err)
+	}
+	if text.Len() == len(prefill) {
+		return ChatResponse{}, errors.New("anthropic returned no text content")
+	}
+	result.Text = text.String()
+
+	return result, nil
+}
+
+func toAnthropicMessage(m ChatMessage) anthropicMessage {
+	msg := anthropicMessage{Role: m.Role}
+	if m.Image != "" {
+		msg.Content = append(msg.Content, anthropicContent{
+			Type: "image",
+			Source: &anthropicSource{
+				Type:      "base64",
+				MediaType: "image/jpeg",
+				Data:      m.Image,
+			},
+		})
+	}
+	if m.Text != "" {
+		msg.Content = append(msg.Content, anthropicContent{Type: "text", Text: m.Text})
+	}
+	return msg
+}
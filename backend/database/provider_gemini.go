@@ -0,0 +1,166 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const geminiDefaultURL string = "https://generativelanguage.googleapis.com"
+
+// Provider for Google Gemini via generateContent endpoint of Generative Language API.
+type geminiProvider struct {
+	baseURL string
+	token   string
+}
+
+func newGeminiProvider(service Service) (*geminiProvider, error) {
+	if service.Token == "" {
+		return nil, fmt.Errorf("token for service %s not set", service.Name)
+	}
+	return &geminiProvider{
+		baseURL: serviceBaseURL(service, geminiDefaultURL),
+		token:   service.Token,
+	}, nil
+}
+
+type geminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *geminiInlineData `json:"inline_data,omitempty"`
+}
+
+type geminiInlineData struct {
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	Contents         []geminiContent         `json:"contents"`
+	GenerationConfig *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+func (p *geminiProvider) request(req ChatRequest) geminiRequest {
+	var body geminiRequest
+	for _, m := range req.Messages {
+		body.Contents = append(body.Contents, toGeminiContent(m))
+	}
+	if req.JSON {
+		body.GenerationConfig = &geminiGenerationConfig{ResponseMimeType: "application/json"}
+	}
+	return body
+}
+
+func (p *geminiProvider) endpoint(model, method string) string {
+	return fmt.Sprintf("%s/v1beta/models/%s:%s", p.baseURL, url.PathEscape(model), method)
+}
+
+func (p *geminiProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	headers := map[string]string{"x-goog-api-key": p.token}
+	var resp geminiResponse
+	err := postJSON(ctx, p.endpoint(req.Model, "generateContent"), headers, p.request(req), &resp)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("gemini: %w", err)
+	}
+	if len(resp.Candidates) == 0 {
+		return ChatResponse{}, errors.New("gemini returned no candidates")
+	}
+
+	result := ChatResponse{Model: req.Model}
+	addGeminiResponse(&result, resp)
+	return result, nil
+}
+
+// Streamed response are Server-Sent Events, each of them holds geminiResponse with next piece of text.
+func (p *geminiProvider) ChatStream(ctx context.Context, req ChatRequest, onToken func(token string)) (ChatResponse, error) {
+	headers := map[string]string{"x-goog-api-key": p.token}
+	result := ChatResponse{Model: req.Model}
+	err := postStream(ctx, p.endpoint(req.Model, "streamGenerateContent")+"?alt=sse", headers, p.request(req), func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("could not unmarshal stream chunk: %w", err)
+		}
+		if token := addGeminiResponse(&result, chunk); token != "" {
+			onToken(token)
+		}
+		return nil
+	})
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("gemini: %w", err)
+	}
+
+	return result, nil
+}
+
+// Add the (partial) geminiResponse to the ChatResponse, returns the added text.
+func addGeminiResponse(r *ChatResponse, resp geminiResponse) string {
+	var text strings.Builder
+	if len(resp.Candidates) > 0 {
+		for _, part := range resp.Candidates[0].Content.Parts {
+			text.WriteString(part.Text)
+		}
+	}
+	r.Text += text.String()
+	if resp.ModelVersion != "" {
+		r.Model = resp.ModelVersion
+	}
+	if resp.UsageMetadata.PromptTokenCount > 0 {
+		r.InputTokens = resp.UsageMetadata.PromptTokenCount
+		r.OutputTokens = resp.UsageMetadata.CandidatesTokenCount
+	}
+	return text.String()
+}
+
+// Gemini calls the assistant "model".
+func toGeminiContent(m ChatMessage) geminiContent {
+	content := geminiContent{Role: "user"}
+	if m.Role == RoleAssistant {
+		content.Role = "model"
+	}
+	if m.Text != "" {
+		content.Parts = append(content.Parts, geminiPart{Text: m.Text})
+	}
+	if m.Image != "" {
+		content.Parts = append(content.Parts, geminiPart{
+			InlineData: &geminiInlineData{MimeType: "image/jpeg", Data: m.Image},
+		})
+	}
+	return content
+}
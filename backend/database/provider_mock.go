@@ -0,0 +1,145 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mock Provider for offline play, exhibitions without internet and CI. It never calls any API.
+// Answers are deterministic - derived from the hash of the first user message,
+// which holds the question and the description, so the same question about the same
+// description gets always the same answer.
+//
+// Configured via Service.URL in the query format, mock:// prefix is optional:
+//
+//	mock://?latency=1500ms&error_rate=0.1&seed=exhibition
+//
+// latency - how long each Chat() takes, Go duration format
+// error_rate - probability 0-1 that the Chat() fails
+// seed - changes the answers while keeping them deterministic
+type mockProvider struct {
+	latency   time.Duration
+	errorRate float64
+	seed      string
+}
+
+func newMockProvider(service Service) (*mockProvider, error) {
+	p := &mockProvider{}
+	config := strings.TrimPrefix(service.URL.String, "mock://")
+	config = strings.TrimPrefix(config, "?")
+	values, err := url.ParseQuery(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mock configuration %q of service %s: %w", service.URL.String, service.Name, err)
+	}
+
+	if latency := values.Get("latency"); latency != "" {
+		p.latency, err = time.ParseDuration(latency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mock latency %q: %w", latency, err)
+		}
+	}
+	if errorRate := values.Get("error_rate"); errorRate != "" {
+		p.errorRate, err = strconv.ParseFloat(errorRate, 64)
+		if err != nil || p.errorRate < 0 || p.errorRate > 1 {
+			return nil, fmt.Errorf("invalid mock error_rate %q, must be between 0 and 1", errorRate)
+		}
+	}
+	p.seed = values.Get("seed")
+
+	return p, nil
+}
+
+const mockTokenDelay = 20 * time.Millisecond
+
+var mockDescriptions = []string{
+	"A middle-aged person with a calm, slightly tired expression and neatly combed hair. They wear a plain dark sweater and look straight into the camera. The posture is upright but relaxed, suggesting someone methodical, reserved and used to routine, who values order more than adventure.",
+	"A young person with a wide, confident smile and a colourful jacket. The eyes are lively and the head is slightly tilted, as if in the middle of a joke. They give the impression of an outgoing, impulsive character who enjoys company and rarely stays at home in the evening.",
+	"An older person with deep wrinkles, short grey hair and a serious, attentive look. The clothing is simple and practical. There is a quiet dignity in the face, hinting at a life of hard work, strong opinions and a certain distrust of novelties.",
+	"A person with glasses, a thoughtful frown and a slightly messy hairstyle. The shirt is buttoned up to the collar. They look like someone who reads a lot, overthinks small decisions and feels more comfortable with books than with crowds.",
+}
+
+func (p *mockProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if p.latency > 0 {
+		select {
+		case <-time.After(p.latency):
+		case <-ctx.Done():
+			return ChatResponse{}, ctx.Err()
+		}
+	}
+	if p.errorRate > 0 && rand.Float64() < p.errorRate {
+		return ChatResponse{}, errors.New("mock: injected error")
+	}
+	if len(req.Messages) == 0 {
+		return ChatResponse{}, errors.New("mock: no messages")
+	}
+
+	first := req.Messages[0]
+	hash := p.hash(first.Text + first.Image)
+	var text string
+	switch {
+	case first.Image != "": // describing the image
+		text = mockDescriptions[hash%uint64(len(mockDescriptions))]
+	case strings.HasPrefix(first.Text, investigatorRole): // investigator eliminating one of the suspects
+		suspects := uint64(max(strings.Count(first.Text, "\nSUSPECT "), 1))
+		text = fmt.Sprintf(`{"reasoning": "The suspect does not fit the answer of the witness.", "eliminate": [%d]}`, hash%suspects+1)
+	case len(req.Messages) == 1: // reflection
+		text = fmt.Sprintf("Thinking about the description, there are reasons for both answers. Still, the overall impression of the person makes me lean towards %s.", mockVerdict(hash))
+	case req.JSON: // decision as structured output
+		text = fmt.Sprintf(`{"answer": "%s"}`, mockVerdict(hash))
+	default: // decision
+		text = mockVerdict(hash)
+	}
+
+	return ChatResponse{
+		Text:         text,
+		Model:        "mock",
+		InputTokens:  len(strings.Fields(first.Text)),
+		OutputTokens: len(strings.Fields(text)),
+	}, nil
+}
+
+// Stream the response word by word, as real models do.
+func (p *mockProvider) ChatStream(ctx context.Context, req ChatRequest, onToken func(token string)) (ChatResponse, error) {
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	for i, word := range strings.SplitAfter(resp.Text, " ") {
+		if i > 0 {
+			time.Sleep(mockTokenDelay)
+		}
+		onToken(word)
+	}
+	return resp, nil
+}
+
+func (p *mockProvider) hash(text string) uint64 {
+	sum := sha256.Sum256([]byte(p.seed + text))
+	return binary.BigEndian.Uint64(sum[0:8])
+}
+
+func mockVerdict(hash uint64) string {
+	if hash%2 == 0 {
+		return "YES"
+	}
+	return "NO"
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const ollamaDefaultURL string = "http://localhost:11434"
+
+// Provider for local models served by Ollama via its native API.
+// Token is optional, it is sent as Bearer token when Ollama runs behind authenticating proxy.
+type ollamaProvider struct {
+	baseURL string
+	token   string
+}
+
+func newOllamaProvider(service Service) (*ollamaProvider, error) {
+	return &ollamaProvider{
+		baseURL: serviceBaseURL(service, ollamaDefaultURL),
+		token:   service.Token,
+	}, nil
+}
+
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   string          `json:"format,omitempty"`
+}
+
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+func (p *ollamaProvider) request(req ChatRequest, stream bool) ollamaRequest {
+	body := ollamaRequest{Model: req.Model, Stream: stream}
+	if req.JSON {
+		body.Format = "json"
+	}
+	for _, m := range req.Messages {
+		msg := ollamaMessage{Role: m.Role, Content: m.Text}
+		if m.Image != "" {
+			msg.Images = []string{m.Image}
+		}
+		body.Messages = append(body.Messages, msg)
+	}
+	return body
+}
+
+func (p *ollamaProvider) headers() map[string]string {
+	headers := map[string]string{}
+	if p.token != "" {
+		headers["Authorization"] = "Bearer " + p.token
+	}
+	return headers
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	var resp ollamaResponse
+	err := postJSON(ctx, p.baseURL+"/api/chat", p.headers(), p.request(req, false), &resp)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("ollama: %w", err)
+	}
+
+	return ChatResponse{
+		Text:         resp.Message.Content,
+		Model:        resp.Model,
+		InputTokens:  resp.PromptEvalCount,
+		OutputTokens: resp.EvalCount,
+	}, nil
+}
+
+// Ollama streams newline delimited JSON objects, the last one has done set and the token counts.
+func (p *ollamaProvider) ChatStream(ctx context.Context, req ChatRequest, onToken func(token string)) (ChatResponse, error) {
+	var result ChatResponse
+	var text strings.Builder
+	err := postStream(ctx, p.baseURL+"/api/chat", p.headers(), p.request(req, true), func(line []byte) error {
+		var chunk struct {
+			ollamaResponse
+			Done  bool   `json:"done"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("could not unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return errors.New(chunk.Error)
+		}
+		result.Model = chunk.Model
+		if chunk.Message.Content != "" {
+			text.WriteString(chunk.Message.Content)
+			onToken(chunk.Message.Content)
+		}
+		if chunk.Done {
+			result.InputTokens = chunk.PromptEvalCount
+			result.OutputTokens = chunk.EvalCount
+		}
+		return nil
+	})
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("ollama: %w", err)
+	}
+	result.Text = text.String()
+
+	return result, nil
+}
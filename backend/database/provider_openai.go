@@ -0,0 +1,142 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Provider for OpenAI and services compatible with OpenAI styled API.
+// If Service defines non-empty URL, it is used as BaseURL - this allows usage of LLM proxies (LiteLLM), DeepSeek etc.
+type openAIProvider struct {
+	client *openai.Client
+}
+
+func newOpenAIProvider(service Service) (*openAIProvider, error) {
+	if service.Token == "" {
+		return nil, fmt.Errorf("token for service %s not set", service.Name)
+	}
+	config := openai.DefaultConfig(service.Token)
+	if service.URL.String != "" {
+		config.BaseURL = service.URL.String
+	}
+	return &openAIProvider{client: openai.NewClientWithConfig(config)}, nil
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	var messages []openai.ChatCompletionMessage
+	for _, m := range req.Messages {
+		messages = append(messages, toOpenAIMessage(m))
+	}
+
+	request := openai.ChatCompletionRequest{
+		Model:    req.Model,
+		Messages: messages,
+	}
+	if req.JSON {
+		request.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+	resp, err := p.client.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return ChatResponse{}, errors.New("openai returned no choices")
+	}
+
+	return ChatResponse{
+		Text:         resp.Choices[0].Message.Content,
+		Model:        resp.Model,
+		InputTokens:  resp.Usage.PromptTokens,
+		OutputTokens: resp.Usage.CompletionTokens,
+	}, nil
+}
+
+func (p *openAIProvider) ChatStream(ctx context.Context, req ChatRequest, onToken func(token string)) (ChatResponse, error) {
+	var messages []openai.ChatCompletionMessage
+	for _, m := range req.Messages {
+		messages = append(messages, toOpenAIMessage(m))
+	}
+
+	request := openai.ChatCompletionRequest{
+		Model:         req.Model,
+		Messages:      messages,
+		Stream:        true,
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	}
+	if req.JSON {
+		request.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+	stream, err := p.client.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer stream.Close()
+
+	var result ChatResponse
+	var text strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ChatResponse{}, err
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.InputTokens = chunk.Usage.PromptTokens
+			result.OutputTokens = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			text.WriteString(chunk.Choices[0].Delta.Content)
+			onToken(chunk.Choices[0].Delta.Content)
+		}
+	}
+	result.Text = text.String()
+
+	return result, nil
+}
+
+func toOpenAIMessage(m ChatMessage) openai.ChatCompletionMessage {
+	if m.Image == "" {
+		return openai.ChatCompletionMessage{Role: m.Role, Content: m.Text}
+	}
+
+	var parts []openai.ChatMessagePart
+	if m.Text != "" {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeText,
+			Text: m.Text,
+		})
+	}
+	parts = append(parts, openai.ChatMessagePart{
+		Type: openai.ChatMessagePartTypeImageURL,
+		ImageURL: &openai.ChatMessageImageURL{
+			URL:    fmt.Sprintf("data:image/jpeg;base64,%s", m.Image),
+			Detail: openai.ImageURLDetailHigh,
+		},
+	})
+	return openai.ChatCompletionMessage{Role: m.Role, MultiContent: parts}
+}
@@ -0,0 +1,208 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Values of Service.API_style, they select the Provider implementation.
+// Empty API_style is treated as APIStyleOpenAI, as most services (LiteLLM, DeepSeek...) speak it.
+const (
+	APIStyleOpenAI    string = "openai"
+	APIStyleDeepSeek  string = "deepseek" // DeepSeek uses OpenAI styled API
+	APIStyleAnthropic string = "anthropic"
+	APIStyleOllama    string = "ollama"
+	APIStyleGemini    string = "gemini"
+	APIStyleMock      string = "mock" // built-in fake for offline play and CI, see mockProvider
+)
+
+const (
+	RoleUser      string = "user"
+	RoleAssistant string = "assistant"
+)
+
+// One message in the conversation with the LLM.
+// Image is optional base64 encoded JPEG, see ImageToBase64().
+type ChatMessage struct {
+	Role  string
+	Text  string
+	Image string
+}
+
+type ChatRequest struct {
+	Model    string
+	Messages []ChatMessage
+	JSON     bool // Ask for JSON object in the response, Providers use structured output if the API supports it
+}
+
+type ChatResponse struct {
+	Text         string
+	Model        string // Model as reported by the Provider, can differ from the requested one (aliases, snapshots)
+	InputTokens  int
+	OutputTokens int
+}
+
+// Provider is the client of one LLM Service. Implementations differ in the API style they speak,
+// but all of them support text chat and images in the user messages.
+type Provider interface {
+	Chat(ctx context.Context, req ChatRequest) (ChatResponse, error)
+}
+
+// StreamingProvider can also stream the response text as it is being generated.
+// onToken is called with each new piece of the text, returned ChatResponse holds the whole text.
+type StreamingProvider interface {
+	Provider
+	ChatStream(ctx context.Context, req ChatRequest, onToken func(token string)) (ChatResponse, error)
+}
+
+// Chat with the Provider and stream the response via onToken if the Provider supports it.
+// Providers without streaming deliver the whole response as a single token.
+func chatStream(ctx context.Context, provider Provider, req ChatRequest, onToken func(token string)) (ChatResponse, error) {
+	if streaming, ok := provider.(StreamingProvider); ok && onToken != nil {
+		return streaming.ChatStream(ctx, req, onToken)
+	}
+	resp, err := provider.Chat(ctx, req)
+	if err == nil && onToken != nil {
+		onToken(resp.Text)
+	}
+	return resp, err
+}
+
+// Get the Provider for the Service based on its API_style.
+func NewProvider(service Service) (Provider, error) {
+	style := strings.ToLower(strings.TrimSpace(service.API_style.String))
+	switch style {
+	case "", APIStyleOpenAI, APIStyleDeepSeek:
+		return newOpenAIProvider(service)
+	case APIStyleAnthropic:
+		return newAnthropicProvider(service)
+	case APIStyleOllama:
+		return newOllamaProvider(service)
+	case APIStyleGemini:
+		return newGeminiProvider(service)
+	case APIStyleMock:
+		return newMockProvider(service)
+	default:
+		return nil, fmt.Errorf("unknown API_style %q of service %s", service.API_style.String, service.Name)
+	}
+}
+
+// MARK: HTTP HELPERS
+
+var httpClient = &http.Client{Timeout: 180 * time.Second}
+
+// Get the base URL of the Service, or fallback if Service does not define any.
+// URLs without scheme (localhost:11434) are considered to be plain http.
+func serviceBaseURL(service Service, fallback string) string {
+	url := strings.TrimSpace(service.URL.String)
+	if url == "" {
+		return fallback
+	}
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	return strings.TrimSuffix(url, "/")
+}
+
+// POST the body as JSON to the url and decode the JSON response into out.
+// Non 2xx responses are returned as errors containing the response body, as APIs explain the problem there.
+func postJSON(ctx context.Context, url string, headers map[string]string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("could not marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("could not unmarshal response: %w", err)
+	}
+	return nil
+}
+
+// POST the body as JSON to the url and call onLine for each non-empty line of the streamed response.
+// Works for both Server-Sent Events (data: prefixed lines) and newline delimited JSON.
+func postStream(ctx context.Context, url string, headers map[string]string, body any, onLine func(line []byte) error) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("could not marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, respBody)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := onLine(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Get the payload of Server-Sent Events data line, ok is false for other lines (event:, comments).
+func sseData(line []byte) ([]byte, bool) {
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	return bytes.TrimSpace(data), ok
+}
@@ -0,0 +1,342 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// MARK: GAME MODES
+
+const (
+	ModeClassic string = "classic" // Question of each Round is assigned
+	ModeChoose  string = "choose"  // Player chooses the Question of each Round from several candidates, like from cards
+	ModeCustom  string = "custom"  // Player chooses from candidates or writes own Question, see AskQuestion()
+	ModeReverse string = "reverse" // Player is the witness and the AI is the investigator, see InvestigateRound()
+)
+
+// Mode of the Game decides how the Game is played. Stored in games table, see Game.Mode.
+type GameMode struct {
+	Name            string
+	Questions       QuestionPolicy
+	QuestionChoices int  // Player chooses the Question from this many candidates, 0 means the Question is assigned
+	CustomQuestions bool // Player can write own Question instead of the offered ones
+	HumanWitness    bool // Player answers the Questions about the criminal shown to them, AI eliminates the Suspects
+}
+
+// Does the player give the Question of the Round, by choosing or writing it?
+func (m GameMode) playerAsks() bool {
+	return m.QuestionChoices > 0 || m.CustomQuestions
+}
+
+// Status of the Game when a new Round starts - waiting for the player to give the Question or for the witness.
+func (m GameMode) roundStartStatus() string {
+	if m.playerAsks() {
+		return StatusAwaitingQuestion
+	}
+	return StatusAwaitingAnswer
+}
+
+// How the Questions of the Rounds are selected.
+type QuestionPolicy struct {
+	Topics        []string // Only Questions of these Topics are asked, empty means all
+	LevelWindow   int      // Prefer Questions of the last LevelWindow unlocked levels, 0 means all unlocked levels equally
+	AvoidRepeats  bool     // Do not ask the same Question twice in the Game, until there is no other left
+	BalanceTopics bool     // Ask the Topic which was asked least in the Game so far
+}
+
+var gameModes = map[string]GameMode{
+	ModeClassic: {
+		Name: ModeClassic,
+		Questions: QuestionPolicy{
+			LevelWindow:   2,
+			AvoidRepeats:  true,
+			BalanceTopics: true,
+		},
+	},
+	ModeChoose: {
+		Name: ModeChoose,
+		Questions: QuestionPolicy{
+			LevelWindow:   2,
+			AvoidRepeats:  true,
+			BalanceTopics: true,
+		},
+		QuestionChoices: 3,
+	},
+	ModeCustom: {
+		Name: ModeCustom,
+		Questions: QuestionPolicy{
+			LevelWindow:   2,
+			AvoidRepeats:  true,
+			BalanceTopics: true,
+		},
+		QuestionChoices: 3,
+		CustomQuestions: true,
+	},
+	ModeReverse: {
+		Name: ModeReverse,
+		Questions: QuestionPolicy{
+			LevelWindow:   2,
+			AvoidRepeats:  true,
+			BalanceTopics: true,
+		},
+		HumanWitness: true,
+	},
+}
+
+// Get the GameMode by its name, empty name is ModeClassic.
+func GetGameMode(name string) (GameMode, error) {
+	if name == "" {
+		name = ModeClassic
+	}
+	mode, ok := gameModes[name]
+	if !ok {
+		return mode, fmt.Errorf("unknown game mode %q", name)
+	}
+	return mode, nil
+}
+
+// MARK: QUESTION SELECTION
+
+// Select the Questions for the next Round of the Investigation according to QuestionPolicy of the Game's mode:
+// one Question, or GameMode.QuestionChoices different Questions for the player to choose from.
+// Questions are drawn only from levels unlocked by the Game so far (see GetLevel()).
+func selectQuestions(q querier, investigationUUID string, random *rand.Rand) ([]Question, GameMode, error) {
+	var gameUUID, modeName string
+	query := "SELECT g.uuid, g.mode FROM investigations i JOIN games g ON g.uuid = i.game_uuid WHERE i.uuid = $1"
+	err := q.QueryRow(query, investigationUUID).Scan(&gameUUID, &modeName)
+	if err != nil {
+		return nil, GameMode{}, fmt.Errorf("could not get game of investigation %s: %w", investigationUUID, err)
+	}
+	mode, err := GetGameMode(modeName)
+	if err != nil {
+		return nil, mode, err
+	}
+	var level int
+	err = q.QueryRow("SELECT COUNT(*) FROM investigations WHERE game_uuid = $1", gameUUID).Scan(&level)
+	if err != nil {
+		return nil, mode, err
+	}
+
+	candidates, err := getQuestions(q, mode.Questions.Topics, max(level, 1))
+	if err != nil {
+		return nil, mode, err
+	}
+	if len(candidates) == 0 {
+		return nil, mode, fmt.Errorf("no questions for game mode %s at level %d", mode.Name, level)
+	}
+
+	asked := make(map[string]int) // UUID of Question -> how many times asked in the Game
+	topics := make(map[string]int)
+	rows, err := q.Query(`SELECT r.question_uuid, COALESCE(qu.Topic, '') FROM rounds r
+		JOIN investigations i ON i.uuid = r.investigation_uuid
+		LEFT JOIN questions qu ON qu.UUID = r.question_uuid
+		WHERE i.game_uuid = $1 AND r.question_uuid != ''`, gameUUID)
+	if err != nil {
+		return nil, mode, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var questionUUID, topic string
+		if err := rows.Scan(&questionUUID, &topic); err != nil {
+			return nil, mode, err
+		}
+		asked[questionUUID]++
+		topics[topic]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mode, err
+	}
+
+	var questions []Question
+	for range max(mode.QuestionChoices, 1) {
+		if len(candidates) == 0 {
+			break
+		}
+		question := pickQuestion(candidates, mode.Questions, max(level, 1), asked, topics, random)
+		log.Printf("Selected question (level %d, topic %s) for level %d: %s\n", question.Level, question.Topic, level, question.English)
+		questions = append(questions, question)
+		// Next candidate must be different, count this one as if it was asked.
+		candidates = slices.DeleteFunc(candidates, func(c Question) bool { return c.UUID == question.UUID })
+		asked[question.UUID]++
+		topics[question.Topic]++
+	}
+	return questions, mode, nil
+}
+
+// Narrow down the candidates step by step, each step is skipped if it would leave no Question.
+func pickQuestion(candidates []Question, policy QuestionPolicy, level int, asked, topics map[string]int, random *rand.Rand) Question {
+	narrow := func(keep func(Question) bool) {
+		var kept []Question
+		for _, c := range candidates {
+			if keep(c) {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) > 0 {
+			candidates = kept
+		}
+	}
+
+	if policy.AvoidRepeats {
+		least := candidates[0]
+		for _, c := range candidates {
+			if asked[c.UUID] < asked[least.UUID] {
+				least = c
+			}
+		}
+		narrow(func(c Question) bool { return asked[c.UUID] == asked[least.UUID] })
+	}
+	if policy.LevelWindow > 0 {
+		narrow(func(c Question) bool { return c.Level > level-policy.LevelWindow })
+	}
+	if policy.BalanceTopics {
+		least := candidates[0].Topic
+		for _, c := range candidates {
+			if topics[c.Topic] < topics[least] {
+				least = c.Topic
+			}
+		}
+		narrow(func(c Question) bool { return topics[c.Topic] == topics[least] })
+	}
+
+	return candidates[random.IntN(len(candidates))]
+}
+
+// Get all approved Questions up to the level, of the topics or of any topic if topics are empty.
+func getQuestions(q querier, topics []string, level int) ([]Question, error) {
+	var questions []Question
+	rows, err := q.Query("SELECT UUID, English, Czech, Polish, Topic, Level FROM questions WHERE Level <= $1 AND approved = 1", level)
+	if err != nil {
+		return questions, err
+	}
+	defer rows.Close()
+
+	allowed := make(map[string]bool)
+	for _, topic := range topics {
+		allowed[topic] = true
+	}
+	for rows.Next() {
+		var question Question
+		err := rows.Scan(&question.UUID, &question.English, &question.Czech, &question.Polish, &question.Topic, &question.Level)
+		if err != nil {
+			return questions, err
+		}
+		if len(allowed) > 0 && !allowed[question.Topic] {
+			continue
+		}
+		question.Approved = true
+		questions = append(questions, question)
+	}
+
+	return questions, rows.Err()
+}
+
+// Save the Questions offered to the player in the Round, in order in which they are shown.
+func saveQuestionOffers(q querier, roundUUID string, questions []Question) error {
+	query := "INSERT INTO question_offers (round_uuid, position, question_uuid, chosen) VALUES (?, ?, ?, 0)"
+	for x, question := range questions {
+		if _, err := q.Exec(query, roundUUID, x+1, question.UUID); err != nil {
+			return fmt.Errorf("could not save offered question %s: %w", question.UUID, err)
+		}
+	}
+	return nil
+}
+
+// Get the Questions offered to the player in the Round, empty if the Question was assigned.
+func getQuestionOffers(q querier, roundUUID string) ([]Question, error) {
+	questionUUIDs, err := queryStrings(q, "SELECT question_uuid FROM question_offers WHERE round_uuid = $1 ORDER BY position", roundUUID)
+	if err != nil {
+		return nil, err
+	}
+	var questions []Question
+	for _, questionUUID := range questionUUIDs {
+		question, err := getQuestion(questionUUID)
+		if err != nil {
+			return questions, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// Save the custom Question written by the player, not approved yet. Question with the same text is reused.
+// Text goes to all languages, the witness gets it as the player wrote it.
+func saveCustomQuestion(q querier, text, authorUUID string) (Question, error) {
+	question := Question{
+		English:    text,
+		Czech:      text,
+		Polish:     text,
+		Topic:      TopicCustom,
+		Level:      1,
+		AuthorUUID: authorUUID,
+	}
+	err := q.QueryRow("SELECT UUID, Topic, Level, author_uuid, approved FROM questions WHERE English = $1 LIMIT 1", text).
+		Scan(&question.UUID, &question.Topic, &question.Level, &question.AuthorUUID, &question.Approved)
+	if err == nil {
+		return question, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return question, err
+	}
+
+	question.UUID = uuid.New().String()
+	query := "INSERT INTO questions (UUID, English, Czech, Polish, Topic, Level, author_uuid, approved) VALUES (?, ?, ?, ?, ?, ?, ?, 0)"
+	_, err = q.Exec(query, question.UUID, question.English, question.Czech, question.Polish, question.Topic, question.Level, question.AuthorUUID)
+	if err != nil {
+		return question, fmt.Errorf("could not save custom question: %w", err)
+	}
+	return question, nil
+}
+
+// Get custom Questions which wait for approval, oldest first.
+func GetPendingQuestions() ([]Question, error) {
+	var questions []Question
+	rows, err := database.Query("SELECT UUID, English, Czech, Polish, Topic, Level, author_uuid FROM questions WHERE approved = 0 ORDER BY rowid")
+	if err != nil {
+		return questions, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var question Question
+		err := rows.Scan(&question.UUID, &question.English, &question.Czech, &question.Polish, &question.Topic, &question.Level, &question.AuthorUUID)
+		if err != nil {
+			return questions, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+// Approve the custom Question, from now on it can be selected for Rounds of any Game like other Questions.
+func ApproveQuestion(questionUUID string) error {
+	result, err := database.Exec("UPDATE questions SET approved = 1 WHERE UUID = $1", questionUUID)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+	log.Printf("Question (%s) approved\n", questionUUID)
+	return nil
+}
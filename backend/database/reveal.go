@@ -0,0 +1,100 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"fmt"
+	"log"
+)
+
+// MARK: REVEAL
+
+// What was hidden during the Investigation: who the criminal was and how the witness saw them.
+// Available only once the Investigation is over.
+type Reveal struct {
+	InvestigationUUID string        `json:"InvestigationUUID"`
+	Criminal          Suspect       `json:"Criminal"`
+	CriminalReleased  bool          `json:"CriminalReleased"` // Player eliminated the criminal and lost the Game
+	Description       *Description  `json:"Description"`      // Description of the criminal the witness was reasoning from, nil if no Round was answered
+	Rounds            []RevealRound `json:"Rounds"`
+}
+
+// Round of the Investigation with the thoughts of the witness behind the Answer.
+type RevealRound struct {
+	RoundUUID    string        `json:"RoundUUID"`
+	Question     Question      `json:"Question"`
+	Verdict      string        `json:"Verdict"`    // YES, NO or REFUSED, empty if the Round was not answered
+	Reflection   string        `json:"Reflection"` // How the witness reasoned about the question
+	Eliminations []Elimination `json:"Eliminations"`
+}
+
+// Get the Reveal of the finished Investigation. Returns RuleError if the Investigation is not over yet.
+func GetReveal(investigationUUID string) (Reveal, error) {
+	reveal := Reveal{InvestigationUUID: investigationUUID}
+	finished, err := IsInvestigationFinished(investigationUUID)
+	if err != nil {
+		return reveal, err
+	}
+	if !finished {
+		return reveal, conflict(ErrInvestigationNotOver, "investigation %s is not over yet", investigationUUID)
+	}
+
+	investigation := Investigation{UUID: investigationUUID}
+	row := database.QueryRow("SELECT game_uuid, timestamp, criminal_uuid, description_uuid FROM investigations WHERE uuid = $1", investigationUUID)
+	err = row.Scan(&investigation.GameUUID, &investigation.Timestamp, &investigation.CriminalUUID, &investigation.DescriptionUUID)
+	if err != nil {
+		return reveal, err
+	}
+	err = loadInvestigation(&investigation)
+	if err != nil {
+		return reveal, err
+	}
+
+	for _, suspect := range investigation.Suspects {
+		if suspect.UUID == investigation.CriminalUUID {
+			reveal.Criminal = suspect
+		}
+	}
+	reveal.CriminalReleased = investigation.CriminalReleased
+
+	descriptionUUID := investigation.DescriptionUUID
+	for _, round := range investigation.Rounds {
+		revealRound := RevealRound{
+			RoundUUID:    round.UUID,
+			Question:     round.Question,
+			Eliminations: round.Eliminations,
+		}
+		if round.AnswerUUID != "" {
+			answer, err := GetAnswerForRound(round.UUID)
+			if err != nil {
+				return reveal, fmt.Errorf("could not get answer of round %s: %w", round.UUID, err)
+			}
+			revealRound.Verdict = answer.Verdict
+			revealRound.Reflection = answer.Reflection
+			if descriptionUUID == "" {
+				descriptionUUID = answer.DescriptionUUID
+			}
+		}
+		reveal.Rounds = append(reveal.Rounds, revealRound)
+	}
+
+	if descriptionUUID != "" {
+		description, err := GetDescription(descriptionUUID)
+		if err != nil {
+			log.Printf("Could not get Description (%s) for the reveal: %v\n", descriptionUUID, err)
+			return reveal, err
+		}
+		reveal.Description = &description
+	}
+
+	return reveal, nil
+}
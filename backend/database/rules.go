@@ -0,0 +1,729 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MARK: GAME STATE
+
+// Status of the Game, stored in games table. Game goes round and round through:
+// awaiting_answer -> awaiting_elimination -> awaiting_answer (next Round) -> ...
+// until the Investigation is solved (-> awaiting_answer of the next Investigation) or the criminal is released.
+// In ModeChoose every Round starts with awaiting_question instead, until the player chooses the Question.
+const (
+	StatusAwaitingQuestion    = "awaiting_question"    // Player chooses the Question of the current Round from the offered ones
+	StatusAwaitingAnswer      = "awaiting_answer"      // Witness is answering the question of the current Round
+	StatusAwaitingElimination = "awaiting_elimination" // Player eliminates Suspects based on the Answer
+	StatusInvestigationSolved = "investigation_solved" // Only the criminal is left, next Investigation can start
+	StatusGameOver            = "game_over"            // Criminal was released, final state
+)
+
+// Allowed transitions between Game statuses.
+var statusTransitions = map[string][]string{
+	StatusAwaitingQuestion:    {StatusAwaitingAnswer},
+	StatusAwaitingAnswer:      {StatusAwaitingElimination},
+	StatusAwaitingElimination: {StatusAwaitingQuestion, StatusAwaitingAnswer, StatusInvestigationSolved, StatusGameOver},
+	StatusInvestigationSolved: {StatusAwaitingQuestion, StatusAwaitingAnswer},
+	StatusGameOver:            {},
+}
+
+// Move the Game from one status to another. Fails if the transition is not allowed
+// or if the Game is not in the from status anymore - somebody else moved it meanwhile.
+func setGameStatus(q querier, gameUUID, from, to string) error {
+	allowed := false
+	for _, status := range statusTransitions[from] {
+		allowed = allowed || status == to
+	}
+	if !allowed {
+		return conflict(ErrInvalidTransition, "game cannot go from %s to %s", from, to)
+	}
+
+	result, err := q.Exec("UPDATE games SET status = $1 WHERE uuid = $2 AND status = $3", to, gameUUID, from)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return conflict(ErrInvalidTransition, "game %s is not %s anymore", gameUUID, from)
+	}
+	log.Printf("Game (%s): %s -> %s\n", gameUUID, from, to)
+	return nil
+}
+
+// MARK: GAME RULES
+
+// Every action of the player is validated against the state of the Game in the database,
+// frontend cannot be trusted with the rules. Broken rule is reported as RuleError.
+
+// Action of the player breaks the rules of the Game. Status is HTTP status to respond with:
+// 409 Conflict when the action is not possible in the current state of the Game,
+// 422 Unprocessable Entity when the action does not make sense at all (e.g. Suspect from other Investigation).
+type RuleError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"error"` // Machine readable, one of the Err* codes
+	Message string `json:"message"`
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Codes of RuleError.
+const (
+	ErrGameOver                = "game_over"
+	ErrInvestigationOver       = "investigation_over"
+	ErrInvestigationNotCurrent = "investigation_not_current"
+	ErrInvestigationNotSolved  = "investigation_not_solved"
+	ErrInvestigationNotOver    = "investigation_not_over"
+	ErrRoundNotCurrent         = "round_not_current"
+	ErrRoundNotEliminated      = "round_not_eliminated"
+	ErrAnswerNotReady          = "answer_not_ready"
+	ErrSuspectEliminated       = "suspect_already_eliminated"
+	ErrUnknownInvestigation    = "unknown_investigation"
+	ErrRoundNotInInvestigation = "round_not_in_investigation"
+	ErrSuspectNotInvestigated  = "suspect_not_in_investigation"
+	ErrInvalidTransition       = "invalid_transition"
+	ErrQuestionNotChosen       = "question_not_chosen"
+	ErrQuestionAlreadyChosen   = "question_already_chosen"
+	ErrQuestionNotOffered      = "question_not_offered"
+	ErrQuestionRejected        = "question_rejected"
+	ErrCustomQuestionsOff      = "custom_questions_not_allowed"
+	ErrWitnessNotHuman         = "witness_not_human"
+	ErrInvestigatorNotHuman    = "investigator_not_human"
+	ErrInvalidVerdict          = "invalid_verdict"
+	ErrInvestigatorBusy        = "investigator_busy"
+)
+
+func conflict(code, format string, args ...any) *RuleError {
+	return &RuleError{Status: http.StatusConflict, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func unprocessable(code, format string, args ...any) *RuleError {
+	return &RuleError{Status: http.StatusUnprocessableEntity, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Common part of *sql.DB and *sql.Tx, so the state can be checked inside of the transaction which changes it.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Snapshot of the Investigation as needed for checking the rules.
+type investigationState struct {
+	UUID         string
+	GameUUID     string
+	CriminalUUID string
+	Status       string          // Status of the Game
+	Mode         string          // Mode of the Game
+	Current      bool            // Latest Investigation of the Game
+	Suspects     map[string]bool // UUIDs of Suspects in the Investigation
+	Eliminated   map[string]bool // UUIDs of Suspects eliminated in any Round
+	Rounds       []string        // UUIDs of Rounds, from oldest to newest
+	LastRound    int             // Number of Eliminations in the last Round
+	LastAnswer   string          // Answer of the last Round, one of Verdict* constants or empty
+}
+
+func loadInvestigationState(q querier, investigationUUID string) (investigationState, error) {
+	s := investigationState{
+		UUID:       investigationUUID,
+		Suspects:   make(map[string]bool),
+		Eliminated: make(map[string]bool),
+	}
+	query := `SELECT i.game_uuid, i.criminal_uuid, g.status, g.mode,
+		i.uuid = (SELECT uuid FROM investigations WHERE game_uuid = i.game_uuid ORDER BY timestamp DESC LIMIT 1)
+		FROM investigations i JOIN games g ON g.uuid = i.game_uuid WHERE i.uuid = $1`
+	err := q.QueryRow(query, investigationUUID).Scan(&s.GameUUID, &s.CriminalUUID, &s.Status, &s.Mode, &s.Current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, unprocessable(ErrUnknownInvestigation, "investigation %s does not exist", investigationUUID)
+	}
+	if err != nil {
+		return s, err
+	}
+
+	suspectUUIDs, err := queryStrings(q, "SELECT suspect_uuid FROM investigation_suspects WHERE investigation_uuid = $1", investigationUUID)
+	if err != nil {
+		return s, err
+	}
+	for _, suspectUUID := range suspectUUIDs {
+		s.Suspects[suspectUUID] = true
+	}
+
+	s.Rounds, err = queryStrings(q, "SELECT uuid FROM rounds WHERE investigation_uuid = $1 ORDER BY timestamp ASC", investigationUUID)
+	if err != nil {
+		return s, err
+	}
+	if s.lastRound() != "" {
+		err = q.QueryRow("SELECT answer FROM rounds WHERE uuid = $1", s.lastRound()).Scan(&s.LastAnswer)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	rows, err := q.Query(`SELECT e.SuspectUUID, e.RoundUUID FROM eliminations e
+		JOIN rounds r ON r.uuid = e.RoundUUID WHERE r.investigation_uuid = $1`, investigationUUID)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var suspectUUID, roundUUID string
+		if err := rows.Scan(&suspectUUID, &roundUUID); err != nil {
+			return s, err
+		}
+		s.Eliminated[suspectUUID] = true
+		if roundUUID == s.lastRound() {
+			s.LastRound++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+func queryStrings(q querier, query string, args ...any) ([]string, error) {
+	var values []string
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return values, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return values, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func (s investigationState) lastRound() string {
+	if len(s.Rounds) == 0 {
+		return ""
+	}
+	return s.Rounds[len(s.Rounds)-1]
+}
+
+// Criminal was eliminated, this ends the Game.
+func (s investigationState) criminalReleased() bool {
+	return s.Eliminated[s.CriminalUUID]
+}
+
+// Only the Criminal is left.
+func (s investigationState) solved() bool {
+	return !s.criminalReleased() && len(s.Eliminated) >= len(s.Suspects)-1
+}
+
+// Investigation is the current one and neither lost nor solved.
+func (s investigationState) checkOpen() error {
+	if !s.Current {
+		return conflict(ErrInvestigationNotCurrent, "investigation %s is not the current investigation of its game", s.UUID)
+	}
+	switch s.Status {
+	case StatusGameOver:
+		return conflict(ErrGameOver, "game is over, the criminal was released")
+	case StatusInvestigationSolved:
+		return conflict(ErrInvestigationOver, "investigation %s is already solved", s.UUID)
+	}
+	return nil
+}
+
+func (s investigationState) checkElimination(suspectUUID, roundUUID string) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if !s.Suspects[suspectUUID] {
+		return unprocessable(ErrSuspectNotInvestigated, "suspect %s is not in investigation %s", suspectUUID, s.UUID)
+	}
+	isRound := false
+	for _, r := range s.Rounds {
+		isRound = isRound || r == roundUUID
+	}
+	if !isRound {
+		return unprocessable(ErrRoundNotInInvestigation, "round %s is not in investigation %s", roundUUID, s.UUID)
+	}
+	if roundUUID != s.lastRound() {
+		return conflict(ErrRoundNotCurrent, "round %s is not the current round", roundUUID)
+	}
+	if s.Status == StatusAwaitingQuestion {
+		return conflict(ErrQuestionNotChosen, "question of round %s was not chosen yet", roundUUID)
+	}
+	if s.Status == StatusAwaitingAnswer {
+		return conflict(ErrAnswerNotReady, "witness has not answered the question of round %s yet", roundUUID)
+	}
+	if s.Eliminated[suspectUUID] {
+		return conflict(ErrSuspectEliminated, "suspect %s was already eliminated", suspectUUID)
+	}
+	return nil
+}
+
+// Check the player can choose the Question of the Round: it must be the current Round still waiting for it.
+func (s investigationState) checkQuestionChoice(roundUUID string) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if roundUUID != s.lastRound() {
+		return conflict(ErrRoundNotCurrent, "round %s is not the current round", roundUUID)
+	}
+	if s.Status != StatusAwaitingQuestion {
+		return conflict(ErrQuestionAlreadyChosen, "question of round %s is already chosen", roundUUID)
+	}
+	return nil
+}
+
+// Check the Game's mode lets the player write own Question.
+func (s investigationState) checkCustomQuestion() error {
+	mode, err := GetGameMode(s.Mode)
+	if err != nil {
+		return err
+	}
+	if !mode.CustomQuestions {
+		return conflict(ErrCustomQuestionsOff, "game mode %s does not allow custom questions", mode.Name)
+	}
+	return nil
+}
+
+// Check the human witness can answer the Question of the Round.
+func (s investigationState) checkWitnessAnswer(roundUUID string) error {
+	if err := s.checkHumanWitness(); err != nil {
+		return err
+	}
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if roundUUID != s.lastRound() {
+		return conflict(ErrRoundNotCurrent, "round %s is not the current round", roundUUID)
+	}
+	if s.Status != StatusAwaitingAnswer {
+		return conflict(ErrInvalidTransition, "question of round %s is already answered", roundUUID)
+	}
+	return nil
+}
+
+// Check the AI investigator can eliminate in the Round: the human witness has answered and nobody was eliminated yet.
+func (s investigationState) checkInvestigator(roundUUID string) error {
+	if err := s.checkHumanWitness(); err != nil {
+		return err
+	}
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if roundUUID != s.lastRound() {
+		return conflict(ErrRoundNotCurrent, "round %s is not the current round", roundUUID)
+	}
+	if s.Status == StatusAwaitingAnswer {
+		return conflict(ErrAnswerNotReady, "witness has not answered the question of round %s yet", roundUUID)
+	}
+	return nil
+}
+
+func (s investigationState) checkHumanWitness() error {
+	mode, err := GetGameMode(s.Mode)
+	if err != nil {
+		return err
+	}
+	if !mode.HumanWitness {
+		return conflict(ErrWitnessNotHuman, "in game mode %s the witness is the AI", mode.Name)
+	}
+	return nil
+}
+
+// Check the player is the investigator, in game modes with human witness the AI investigator eliminates.
+func (s investigationState) checkHumanInvestigator() error {
+	mode, err := GetGameMode(s.Mode)
+	if err != nil {
+		return err
+	}
+	if mode.HumanWitness {
+		return conflict(ErrInvestigatorNotHuman, "in game mode %s the investigator is the AI", mode.Name)
+	}
+	return nil
+}
+
+// Check the Investigation can go on with the next Round: at least one Suspect must be eliminated in the current one,
+// unless the witness refused to answer - then there is nothing to eliminate by.
+func (s investigationState) checkNextRound() error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	if s.Status == StatusAwaitingQuestion {
+		return conflict(ErrQuestionNotChosen, "question of the current round was not chosen yet")
+	}
+	if s.Status == StatusAwaitingAnswer {
+		return conflict(ErrAnswerNotReady, "witness has not answered the question of the current round yet")
+	}
+	if s.LastRound == 0 && s.LastAnswer != VerdictRefused {
+		return conflict(ErrRoundNotEliminated, "eliminate at least one suspect before the next round")
+	}
+	return nil
+}
+
+// Check the Game can go on with the next Investigation: the current one must be solved.
+func (s investigationState) checkNextInvestigation() error {
+	if !s.Current {
+		return conflict(ErrInvestigationNotCurrent, "investigation %s is not the current investigation of its game", s.UUID)
+	}
+	if s.Status == StatusGameOver {
+		return conflict(ErrGameOver, "game is over, the criminal was released")
+	}
+	if s.Status != StatusInvestigationSolved {
+		return conflict(ErrInvestigationNotSolved, "investigation %s is not solved yet", s.UUID)
+	}
+	return nil
+}
+
+// Start the next Round of the Investigation, if the rules allow it.
+func NextRound(investigationUUID string) (Round, error) {
+	return nextRound(investigationUUID, newRandom())
+}
+
+func nextRound(investigationUUID string, random *rand.Rand) (Round, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return Round{}, err
+	}
+	defer tx.Rollback()
+
+	state, err := loadInvestigationState(tx, investigationUUID)
+	if err != nil {
+		return Round{}, err
+	}
+	if err := state.checkNextRound(); err != nil {
+		return Round{}, err
+	}
+	round, mode, err := createRound(tx, investigationUUID, random)
+	if err != nil {
+		return round, err
+	}
+	status := StatusAwaitingAnswer
+	if round.Question.UUID == "" {
+		status = StatusAwaitingQuestion
+	}
+	if err := setGameStatus(tx, state.GameUUID, StatusAwaitingElimination, status); err != nil {
+		return round, err
+	}
+	if err := tx.Commit(); err != nil {
+		return round, err
+	}
+
+	if !mode.HumanWitness {
+		enqueueRoundAnswer(round)
+	}
+	return round, nil
+}
+
+// Start the next Investigation of the Game, if the rules allow it - current Investigation must be solved.
+func NextInvestigation(gameUUID string, suspectsCount int) (Investigation, error) {
+	return nextInvestigation(gameUUID, suspectsCount, newRandom())
+}
+
+func nextInvestigation(gameUUID string, suspectsCount int, random *rand.Rand) (Investigation, error) {
+	var investigationUUID string
+	query := "SELECT uuid FROM investigations WHERE game_uuid = $1 ORDER BY timestamp DESC LIMIT 1"
+	err := database.QueryRow(query, gameUUID).Scan(&investigationUUID)
+	if err != nil {
+		return Investigation{}, fmt.Errorf("could not get current investigation of game %s: %w", gameUUID, err)
+	}
+	state, err := loadInvestigationState(database, investigationUUID)
+	if err != nil {
+		return Investigation{}, err
+	}
+	if err := state.checkNextInvestigation(); err != nil {
+		return Investigation{}, err
+	}
+	var modeName string
+	if err := database.QueryRow("SELECT mode FROM games WHERE uuid = $1", gameUUID).Scan(&modeName); err != nil {
+		return Investigation{}, err
+	}
+	mode, err := GetGameMode(modeName)
+	if err != nil {
+		return Investigation{}, err
+	}
+	// Claim the transition first, so concurrent requests cannot start two Investigations.
+	if err := setGameStatus(database, gameUUID, StatusInvestigationSolved, mode.roundStartStatus()); err != nil {
+		return Investigation{}, err
+	}
+
+	investigation, err := newInvestigation(gameUUID, suspectsCount, random)
+	if err != nil {
+		_, rollbackErr := database.Exec("UPDATE games SET status = $1 WHERE uuid = $2", StatusInvestigationSolved, gameUUID)
+		if rollbackErr != nil {
+			log.Printf("Could not return Game (%s) to %s: %v\n", gameUUID, StatusInvestigationSolved, rollbackErr)
+		}
+	}
+	return investigation, err
+}
+
+// Player chooses the Question of the current Round from the offered ones, only then the witness starts answering.
+// Both the offered and the chosen Questions stay recorded in question_offers.
+func ChooseQuestion(roundUUID, questionUUID string) (Round, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return Round{}, err
+	}
+	defer tx.Rollback()
+
+	round, state, err := loadQuestionRound(tx, roundUUID)
+	if err != nil {
+		return round, err
+	}
+	result, err := tx.Exec("UPDATE question_offers SET chosen = 1 WHERE round_uuid = $1 AND question_uuid = $2", roundUUID, questionUUID)
+	if err != nil {
+		return round, err
+	}
+	offered, err := result.RowsAffected()
+	if err != nil {
+		return round, err
+	}
+	if offered == 0 {
+		return round, unprocessable(ErrQuestionNotOffered, "question %s was not offered in round %s", questionUUID, roundUUID)
+	}
+	if err := setRoundQuestion(tx, state, roundUUID, questionUUID); err != nil {
+		return round, err
+	}
+	if err := tx.Commit(); err != nil {
+		return round, err
+	}
+	log.Printf("Question (%s) chosen for Round (%s)\n", questionUUID, roundUUID)
+	return startAnswering(round, questionUUID)
+}
+
+// Player writes own Question for the current Round instead of choosing one of the offered, in modes with
+// GameMode.CustomQuestions. Question must pass ModerateQuestion(), then it is saved with TopicCustom as written
+// by the player of the Game. It is asked only in this Round, until an admin approves it by ApproveQuestion().
+func AskQuestion(roundUUID, text string) (Round, error) {
+	text = strings.TrimSpace(text)
+	// Check the rules before the moderation, which can take a while. Checked again in the transaction below.
+	round, state, err := loadQuestionRound(database, roundUUID)
+	if err != nil {
+		return Round{}, err
+	}
+	if err := state.checkCustomQuestion(); err != nil {
+		return Round{}, err
+	}
+	if err := ModerateQuestion(context.Background(), text); err != nil {
+		log.Printf("Custom question for Round (%s) refused: %v\n", roundUUID, err)
+		return Round{}, err
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return Round{}, err
+	}
+	defer tx.Rollback()
+
+	round, state, err = loadQuestionRound(tx, roundUUID)
+	if err != nil {
+		return round, err
+	}
+	if err := state.checkCustomQuestion(); err != nil {
+		return round, err
+	}
+	var authorUUID string
+	if err := tx.QueryRow("SELECT player_uuid FROM games WHERE uuid = $1", state.GameUUID).Scan(&authorUUID); err != nil {
+		return round, err
+	}
+	question, err := saveCustomQuestion(tx, text, authorUUID)
+	if err != nil {
+		return round, err
+	}
+	if err := setRoundQuestion(tx, state, roundUUID, question.UUID); err != nil {
+		return round, err
+	}
+	if err := tx.Commit(); err != nil {
+		return round, err
+	}
+	log.Printf("Custom question (%s) asked in Round (%s): %s\n", question.UUID, roundUUID, text)
+	return startAnswering(round, question.UUID)
+}
+
+// Human witness answers the Question of the current Round in ModeReverse, YES or NO about the criminal shown to them.
+// Then the AI investigator eliminates, see InvestigateRound().
+func AnswerQuestion(roundUUID, verdict string) (Answer, error) {
+	verdict = strings.ToUpper(strings.TrimSpace(verdict))
+	if verdict != VerdictYes && verdict != VerdictNo {
+		return Answer{}, unprocessable(ErrInvalidVerdict, "answer must be %s or %s, got %q", VerdictYes, VerdictNo, verdict)
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return Answer{}, err
+	}
+	defer tx.Rollback()
+
+	var investigationUUID string
+	err = tx.QueryRow("SELECT investigation_uuid FROM rounds WHERE uuid = $1", roundUUID).Scan(&investigationUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Answer{}, unprocessable(ErrRoundNotInInvestigation, "round %s does not exist", roundUUID)
+	}
+	if err != nil {
+		return Answer{}, err
+	}
+	state, err := loadInvestigationState(tx, investigationUUID)
+	if err != nil {
+		return Answer{}, err
+	}
+	if err := state.checkWitnessAnswer(roundUUID); err != nil {
+		return Answer{}, err
+	}
+
+	answer := Answer{
+		UUID:      uuid.New().String(),
+		RoundUUID: roundUUID,
+		Text:      verdict,
+		Verdict:   verdict,
+		Attempts:  1,
+		Service:   ServiceHuman,
+		Model:     ServiceHuman,
+		Timestamp: TimestampNow(),
+	}
+	if err := saveAnswer(tx, answer, roundUUID); err != nil {
+		return answer, err
+	}
+	if err := tx.Commit(); err != nil {
+		return answer, err
+	}
+	log.Printf("Human witness answered Round (%s): %s\n", roundUUID, verdict)
+	return answer, nil
+}
+
+// Load the Round which waits for the player to give its Question, together with the state of its Investigation.
+func loadQuestionRound(q querier, roundUUID string) (Round, investigationState, error) {
+	round := Round{UUID: roundUUID}
+	err := q.QueryRow("SELECT investigation_uuid, timestamp FROM rounds WHERE uuid = $1", roundUUID).Scan(&round.InvestigationUUID, &round.Timestamp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return round, investigationState{}, unprocessable(ErrRoundNotInInvestigation, "round %s does not exist", roundUUID)
+	}
+	if err != nil {
+		return round, investigationState{}, err
+	}
+	state, err := loadInvestigationState(q, round.InvestigationUUID)
+	if err != nil {
+		return round, state, err
+	}
+	return round, state, state.checkQuestionChoice(roundUUID)
+}
+
+// Set the Question of the Round, witness can start answering.
+func setRoundQuestion(q querier, state investigationState, roundUUID, questionUUID string) error {
+	if _, err := q.Exec("UPDATE rounds SET question_uuid = $1 WHERE uuid = $2", questionUUID, roundUUID); err != nil {
+		return err
+	}
+	return setGameStatus(q, state.GameUUID, StatusAwaitingQuestion, StatusAwaitingAnswer)
+}
+
+// Question of the Round is given, start generating the Answer in the background.
+func startAnswering(round Round, questionUUID string) (Round, error) {
+	var err error
+	round.Question, err = getQuestion(questionUUID)
+	if err != nil {
+		return round, err
+	}
+	round.QuestionOffers, err = getQuestionOffers(database, round.UUID)
+	if err != nil {
+		return round, err
+	}
+	enqueueRoundAnswer(round)
+	return round, nil
+}
+
+// Answer for the current Round is ready, player can eliminate. Answers of other Rounds
+// (e.g. regenerated by admin) do not change the Game.
+func answerSaved(q querier, roundUUID string) error {
+	var investigationUUID string
+	err := q.QueryRow("SELECT investigation_uuid FROM rounds WHERE uuid = $1", roundUUID).Scan(&investigationUUID)
+	if err != nil {
+		return err
+	}
+	state, err := loadInvestigationState(q, investigationUUID)
+	if err != nil {
+		return err
+	}
+	if !state.Current || state.lastRound() != roundUUID || state.Status != StatusAwaitingAnswer {
+		return nil
+	}
+	return setGameStatus(q, state.GameUUID, StatusAwaitingAnswer, StatusAwaitingElimination)
+}
+
+// Save the Elimination of the Suspect in the current Round and increase the Game.Score,
+// unless the Criminal was released. Everything happens in one transaction, so the same
+// Suspect cannot be eliminated (and scored) twice. The player cannot eliminate in game modes with human witness.
+func SaveElimination(suspectUUID, roundUUID, investigationUUID string) error {
+	return saveElimination(suspectUUID, roundUUID, investigationUUID, true)
+}
+
+// Save the Elimination by the player, or by the AI investigator (see applyDecision()) if byPlayer is false.
+func saveElimination(suspectUUID, roundUUID, investigationUUID string, byPlayer bool) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	state, err := loadInvestigationState(tx, investigationUUID)
+	if err != nil {
+		return err
+	}
+	if byPlayer {
+		if err := state.checkHumanInvestigator(); err != nil {
+			return err
+		}
+	}
+	if err := state.checkElimination(suspectUUID, roundUUID); err != nil {
+		log.Printf("Elimination of Suspect (%s) on Round (%s) refused: %v\n", suspectUUID, roundUUID, err)
+		return err
+	}
+
+	query := `INSERT INTO eliminations (UUID, RoundUUID, SuspectUUID, Timestamp) VALUES (?, ?, ?, ?)`
+	_, err = tx.Exec(query, uuid.New().String(), roundUUID, suspectUUID, TimestampNow())
+	if err != nil {
+		log.Printf("Could not save elimination of Suspect (%s) on Round (%s): %v\n", suspectUUID, roundUUID, err)
+		return err
+	}
+
+	state.Eliminated[suspectUUID] = true
+	if state.criminalReleased() {
+		log.Println("Guilty criminal was released :(")
+		if err := setGameStatus(tx, state.GameUUID, state.Status, StatusGameOver); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	err = increaseScore(tx, state.GameUUID, state.LastRound+1)
+	if err != nil {
+		return err
+	}
+	if state.solved() {
+		if err := setGameStatus(tx, state.GameUUID, state.Status, StatusInvestigationSolved); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,307 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// Use a fresh database created from default.db and migrated, like on the first start of the server.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	if err := EnsureDBAvailable(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("could not create test database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+}
+
+func startTestGame(t *testing.T, mode string, suspects int) Game {
+	t.Helper()
+	game, err := NewGame("test-player", "mock", suspects, mode)
+	if err != nil {
+		t.Fatalf("NewGame() error: %v", err)
+	}
+	return game
+}
+
+// Let the witness answer the Round, the Game goes to awaiting_elimination.
+func answerTestRound(t *testing.T, roundUUID, verdict string) {
+	t.Helper()
+	if err := SaveAnswer(Answer{Text: verdict, Verdict: verdict}, roundUUID); err != nil {
+		t.Fatalf("SaveAnswer() error: %v", err)
+	}
+}
+
+func innocentSuspects(i Investigation) []string {
+	var innocents []string
+	for _, suspect := range i.Suspects {
+		if suspect.UUID != i.CriminalUUID {
+			innocents = append(innocents, suspect.UUID)
+		}
+	}
+	return innocents
+}
+
+func gameStatusAndScore(t *testing.T, gameUUID string) (string, int) {
+	t.Helper()
+	var status string
+	var score int
+	err := database.QueryRow("SELECT status, score FROM games WHERE uuid = $1", gameUUID).Scan(&status, &score)
+	if err != nil {
+		t.Fatalf("could not get game %s: %v", gameUUID, err)
+	}
+	return status, score
+}
+
+// Code of the RuleError, empty for nil and other errors.
+func ruleCode(err error) string {
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) {
+		return ruleErr.Code
+	}
+	return ""
+}
+
+func TestSaveEliminationRefused(t *testing.T) {
+	useTestDB(t)
+	tests := []struct {
+		name string
+		mode string
+		// Prepare the Game and return the elimination to try: suspect, round and investigation UUIDs.
+		prepare func(t *testing.T, game Game) (string, string, string)
+		want    string
+	}{
+		{"answer not ready", ModeClassic, func(t *testing.T, game Game) (string, string, string) {
+			i := game.Investigation
+			return innocentSuspects(i)[0], i.Rounds[0].UUID, i.UUID
+		}, ErrAnswerNotReady},
+		{"suspect not in investigation", ModeClassic, func(t *testing.T, game Game) (string, string, string) {
+			i := game.Investigation
+			answerTestRound(t, i.Rounds[0].UUID, VerdictYes)
+			return "no-such-suspect", i.Rounds[0].UUID, i.UUID
+		}, ErrSuspectNotInvestigated},
+		{"round of other investigation", ModeClassic, func(t *testing.T, game Game) (string, string, string) {
+			i := game.Investigation
+			answerTestRound(t, i.Rounds[0].UUID, VerdictYes)
+			other := startTestGame(t, ModeClassic, 3)
+			return innocentSuspects(i)[0], other.Investigation.Rounds[0].UUID, i.UUID
+		}, ErrRoundNotInInvestigation},
+		{"suspect already eliminated", ModeClassic, func(t *testing.T, game Game) (string, string, string) {
+			i := game.Investigation
+			answerTestRound(t, i.Rounds[0].UUID, VerdictYes)
+			if err := SaveElimination(innocentSuspects(i)[0], i.Rounds[0].UUID, i.UUID); err != nil {
+				t.Fatalf("SaveElimination() error: %v", err)
+			}
+			return innocentSuspects(i)[0], i.Rounds[0].UUID, i.UUID
+		}, ErrSuspectEliminated},
+		{"game over", ModeClassic, func(t *testing.T, game Game) (string, string, string) {
+			i := game.Investigation
+			answerTestRound(t, i.Rounds[0].UUID, VerdictYes)
+			if err := SaveElimination(i.CriminalUUID, i.Rounds[0].UUID, i.UUID); err != nil {
+				t.Fatalf("SaveElimination() error: %v", err)
+			}
+			return innocentSuspects(i)[0], i.Rounds[0].UUID, i.UUID
+		}, ErrGameOver},
+		{"player is the witness", ModeReverse, func(t *testing.T, game Game) (string, string, string) {
+			i := game.Investigation
+			if _, err := AnswerQuestion(i.Rounds[0].UUID, VerdictYes); err != nil {
+				t.Fatalf("AnswerQuestion() error: %v", err)
+			}
+			return innocentSuspects(i)[0], i.Rounds[0].UUID, i.UUID
+		}, ErrInvestigatorNotHuman},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			game := startTestGame(t, test.mode, 4)
+			suspectUUID, roundUUID, investigationUUID := test.prepare(t, game)
+			_, scoreBefore := gameStatusAndScore(t, game.UUID)
+			err := SaveElimination(suspectUUID, roundUUID, investigationUUID)
+			if code := ruleCode(err); code != test.want {
+				t.Fatalf("SaveElimination() error = %v, want %s", err, test.want)
+			}
+			if _, score := gameStatusAndScore(t, game.UUID); score != scoreBefore {
+				t.Errorf("refused elimination changed score from %d to %d", scoreBefore, score)
+			}
+		})
+	}
+}
+
+func TestSaveEliminationScoring(t *testing.T) {
+	useTestDB(t)
+	game := startTestGame(t, ModeClassic, 4)
+	i := game.Investigation
+	round := i.Rounds[0].UUID
+	answerTestRound(t, round, VerdictNo)
+	innocents := innocentSuspects(i)
+
+	// Each next elimination in the same Round is worth more: level × eliminations in the Round.
+	steps := []struct {
+		suspectUUID string
+		status      string
+		score       int
+	}{
+		{innocents[0], StatusAwaitingElimination, 1},
+		{innocents[1], StatusAwaitingElimination, 3},
+		{innocents[2], StatusInvestigationSolved, 6},
+	}
+	for _, step := range steps {
+		if err := SaveElimination(step.suspectUUID, round, i.UUID); err != nil {
+			t.Fatalf("SaveElimination() error: %v", err)
+		}
+		status, score := gameStatusAndScore(t, game.UUID)
+		if status != step.status || score != step.score {
+			t.Errorf("after eliminating %s: status %s, score %d, want %s, %d", step.suspectUUID, status, score, step.status, step.score)
+		}
+	}
+}
+
+func TestSaveEliminationCriminalReleased(t *testing.T) {
+	useTestDB(t)
+	game := startTestGame(t, ModeClassic, 4)
+	i := game.Investigation
+	answerTestRound(t, i.Rounds[0].UUID, VerdictYes)
+	if err := SaveElimination(innocentSuspects(i)[0], i.Rounds[0].UUID, i.UUID); err != nil {
+		t.Fatalf("SaveElimination() error: %v", err)
+	}
+	if err := SaveElimination(i.CriminalUUID, i.Rounds[0].UUID, i.UUID); err != nil {
+		t.Fatalf("SaveElimination() of the criminal error: %v", err)
+	}
+	status, score := gameStatusAndScore(t, game.UUID)
+	if status != StatusGameOver || score != 1 {
+		t.Errorf("after releasing the criminal: status %s, score %d, want %s, 1", status, score, StatusGameOver)
+	}
+}
+
+func TestSetGameStatus(t *testing.T) {
+	useTestDB(t)
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{"answer is ready", StatusAwaitingAnswer, StatusAwaitingElimination, ""},
+		{"skip the answer", StatusAwaitingAnswer, StatusInvestigationSolved, ErrInvalidTransition},
+		{"game over is final", StatusGameOver, StatusAwaitingAnswer, ErrInvalidTransition},
+		{"game is not in from anymore", StatusAwaitingElimination, StatusAwaitingAnswer, ErrInvalidTransition},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			game := startTestGame(t, ModeClassic, 3) // awaiting_answer
+			err := setGameStatus(database, game.UUID, test.from, test.to)
+			if code := ruleCode(err); code != test.want {
+				t.Fatalf("setGameStatus(%s, %s) error = %v, want %q", test.from, test.to, err, test.want)
+			}
+			want := StatusAwaitingAnswer
+			if test.want == "" {
+				want = test.to
+			}
+			if status, _ := gameStatusAndScore(t, game.UUID); status != want {
+				t.Errorf("status is %s, want %s", status, want)
+			}
+		})
+	}
+}
+
+func TestNextRound(t *testing.T) {
+	useTestDB(t)
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, i Investigation)
+		want    string
+	}{
+		{"answer not ready", func(t *testing.T, i Investigation) {}, ErrAnswerNotReady},
+		{"nobody eliminated", func(t *testing.T, i Investigation) {
+			answerTestRound(t, i.Rounds[0].UUID, VerdictYes)
+		}, ErrRoundNotEliminated},
+		{"witness refused", func(t *testing.T, i Investigation) {
+			answerTestRound(t, i.Rounds[0].UUID, VerdictRefused)
+		}, ""},
+		{"suspect eliminated", func(t *testing.T, i Investigation) {
+			answerTestRound(t, i.Rounds[0].UUID, VerdictYes)
+			if err := SaveElimination(innocentSuspects(i)[0], i.Rounds[0].UUID, i.UUID); err != nil {
+				t.Fatalf("SaveElimination() error: %v", err)
+			}
+		}, ""},
+		{"game over", func(t *testing.T, i Investigation) {
+			answerTestRound(t, i.Rounds[0].UUID, VerdictYes)
+			if err := SaveElimination(i.CriminalUUID, i.Rounds[0].UUID, i.UUID); err != nil {
+				t.Fatalf("SaveElimination() error: %v", err)
+			}
+		}, ErrGameOver},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			game := startTestGame(t, ModeClassic, 4)
+			test.prepare(t, game.Investigation)
+			statusBefore, _ := gameStatusAndScore(t, game.UUID)
+			round, err := NextRound(game.Investigation.UUID)
+			if code := ruleCode(err); code != test.want {
+				t.Fatalf("NextRound() error = %v, want %q", err, test.want)
+			}
+			status, _ := gameStatusAndScore(t, game.UUID)
+			if test.want != "" {
+				if status != statusBefore {
+					t.Errorf("refused NextRound() changed status from %s to %s", statusBefore, status)
+				}
+				return
+			}
+			if round.InvestigationUUID != game.Investigation.UUID || status != StatusAwaitingAnswer {
+				t.Errorf("NextRound() created round of %s, status %s, want round of %s, status %s",
+					round.InvestigationUUID, status, game.Investigation.UUID, StatusAwaitingAnswer)
+			}
+		})
+	}
+}
+
+func TestNextInvestigation(t *testing.T) {
+	useTestDB(t)
+	game := startTestGame(t, ModeClassic, 3)
+	i := game.Investigation
+	answerTestRound(t, i.Rounds[0].UUID, VerdictNo)
+	innocents := innocentSuspects(i)
+
+	if err := SaveElimination(innocents[0], i.Rounds[0].UUID, i.UUID); err != nil {
+		t.Fatalf("SaveElimination() error: %v", err)
+	}
+	if _, err := NextInvestigation(game.UUID, 3); ruleCode(err) != ErrInvestigationNotSolved {
+		t.Fatalf("NextInvestigation() of unsolved investigation error = %v, want %s", err, ErrInvestigationNotSolved)
+	}
+
+	if err := SaveElimination(innocents[1], i.Rounds[0].UUID, i.UUID); err != nil {
+		t.Fatalf("SaveElimination() error: %v", err)
+	}
+	next, err := NextInvestigation(game.UUID, 3)
+	if err != nil {
+		t.Fatalf("NextInvestigation() error: %v", err)
+	}
+	if status, _ := gameStatusAndScore(t, game.UUID); status != StatusAwaitingAnswer {
+		t.Errorf("status after NextInvestigation() is %s, want %s", status, StatusAwaitingAnswer)
+	}
+	if level, err := GetLevel(game.UUID); err != nil || level != 2 {
+		t.Errorf("GetLevel() = %d, %v, want 2", level, err)
+	}
+
+	// Only one Investigation can follow the solved one.
+	if _, err := NextInvestigation(game.UUID, 3); ruleCode(err) != ErrInvestigationNotSolved {
+		t.Errorf("repeated NextInvestigation() error = %v, want %s", err, ErrInvestigationNotSolved)
+	}
+	// Eliminations in the old Investigation are refused.
+	err = SaveElimination(i.CriminalUUID, i.Rounds[0].UUID, i.UUID)
+	if ruleCode(err) != ErrInvestigationNotCurrent {
+		t.Errorf("SaveElimination() in old investigation error = %v, want %s", err, ErrInvestigationNotCurrent)
+	}
+	if len(next.Rounds) != 1 {
+		t.Errorf("next investigation has %d rounds, want 1", len(next.Rounds))
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// MARK: SIMULATION
+
+// Headless self-play: one Model is the witness, another Model (or a rule-based strategy) is the investigator.
+// Games go through the same code and rules as the games of human players, so they give the same bias data.
+// Simulated Games are marked by games.simulation_uuid, they are left out of the scores and by default of the statistics.
+
+// Rule-based investigator which eliminates one random Suspect each Round, ignoring the Answer.
+// Baseline to compare the Models with.
+const InvestigatorRandom string = "random"
+
+type Simulation struct {
+	UUID              string `json:"UUID"`
+	Witness           string `json:"Witness"`      // Model which answers the Questions
+	Investigator      string `json:"Investigator"` // Model which eliminates the Suspects, or InvestigatorRandom
+	Seed              uint64 `json:"Seed"`         // Seed of the Suspects, criminals, Descriptions, Questions and the rule-based investigator
+	Games             int    `json:"Games"`
+	Suspects          int    `json:"Suspects"`          // Suspects in each Investigation, 0 means DefaultSuspects
+	MaxInvestigations int    `json:"MaxInvestigations"` // Game is stopped after this many Investigations, 0 means no limit
+	Timestamp         string `json:"Timestamp"`
+}
+
+// How one simulated Game ended.
+type SimulatedGame struct {
+	GameUUID       string `json:"GameUUID"`
+	Status         string `json:"Status"` // StatusGameOver, or other if the Game was stopped by MaxInvestigations
+	Investigations int    `json:"Investigations"`
+	Rounds         int    `json:"Rounds"`
+	Score          int    `json:"Score"`
+}
+
+// Play the Games of the Simulation one by one, onGame is called after each of them.
+// Failed Game stops the Simulation, Games played so far stay in the database.
+func Simulate(s Simulation, onGame func(SimulatedGame)) (Simulation, error) {
+	s.UUID = uuid.New().String()
+	s.Timestamp = TimestampNow()
+	if s.Suspects == 0 {
+		s.Suspects = DefaultSuspects
+	}
+	if _, err := GetModel(s.Witness); err != nil {
+		return s, fmt.Errorf("unknown witness model %s: %w", s.Witness, err)
+	}
+	if s.Investigator != InvestigatorRandom {
+		if _, err := GetModel(s.Investigator); err != nil {
+			return s, fmt.Errorf("unknown investigator model %s: %w", s.Investigator, err)
+		}
+	}
+	query := `INSERT INTO simulations (uuid, witness, investigator, seed, games, suspects, max_investigations, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := database.Exec(query, s.UUID, s.Witness, s.Investigator, s.Seed, s.Games, s.Suspects, s.MaxInvestigations, s.Timestamp)
+	if err != nil {
+		return s, err
+	}
+
+	random := rand.New(rand.NewPCG(s.Seed, s.Seed))
+	for x := range s.Games {
+		game, err := simulateGame(s, random)
+		if err != nil {
+			return s, fmt.Errorf("simulated game %d of %d failed: %w", x+1, s.Games, err)
+		}
+		if onGame != nil {
+			onGame(game)
+		}
+	}
+	return s, nil
+}
+
+func simulateGame(s Simulation, random *rand.Rand) (SimulatedGame, error) {
+	game, err := newGame("", s.Witness, s.Suspects, ModeClassic, s.UUID, random)
+	if err != nil {
+		return SimulatedGame{}, err
+	}
+	result := SimulatedGame{GameUUID: game.UUID, Investigations: 1, Rounds: 1}
+	investigationUUID := game.Investigation.UUID
+	roundUUID := game.Investigation.Rounds[0].UUID
+
+	for {
+		if _, err := GetOrGenerateAnswer(roundUUID); err != nil {
+			return result, err
+		}
+		state, err := loadInvestigationState(database, investigationUUID)
+		if err != nil {
+			return result, err
+		}
+		decision, err := simulateInvestigator(s, roundUUID, state, random)
+		if err != nil {
+			return result, err
+		}
+		if err := applyDecision(decision, investigationUUID); err != nil {
+			return result, err
+		}
+
+		err = database.QueryRow("SELECT status, score FROM games WHERE uuid = $1", game.UUID).Scan(&result.Status, &result.Score)
+		if err != nil {
+			return result, err
+		}
+		switch result.Status {
+		case StatusGameOver:
+			return result, nil
+		case StatusInvestigationSolved:
+			if s.MaxInvestigations > 0 && result.Investigations >= s.MaxInvestigations {
+				return result, nil
+			}
+			investigation, err := nextInvestigation(game.UUID, s.Suspects, random)
+			if err != nil {
+				return result, err
+			}
+			result.Investigations++
+			investigationUUID = investigation.UUID
+			roundUUID = investigation.Rounds[0].UUID
+		default:
+			round, err := nextRound(investigationUUID, random)
+			if err != nil {
+				return result, err
+			}
+			roundUUID = round.UUID
+		}
+		result.Rounds++
+	}
+}
+
+// Decide which Suspects the investigator of the Simulation eliminates in the answered Round.
+// Decisions of the Model are saved like in ModeReverse, the rule-based ones are not.
+func simulateInvestigator(s Simulation, roundUUID string, state investigationState, random *rand.Rand) (InvestigatorDecision, error) {
+	if s.Investigator == InvestigatorRandom {
+		var left []string
+		for suspectUUID := range state.Suspects {
+			if !state.Eliminated[suspectUUID] {
+				left = append(left, suspectUUID)
+			}
+		}
+		slices.Sort(left) // map order is random, the seed alone must decide
+		return InvestigatorDecision{RoundUUID: roundUUID, Eliminated: []string{left[random.IntN(len(left))]}}, nil
+	}
+
+	decision, err := askInvestigator(s.Investigator, roundUUID, state)
+	if err != nil {
+		return decision, err
+	}
+	log.Printf("Simulated investigator %s eliminates %d suspects: %s\n", s.Investigator, len(decision.Eliminated), decision.Reasoning)
+	return decision, saveInvestigatorDecision(decision)
+}
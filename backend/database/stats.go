@@ -0,0 +1,227 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MARK: STATISTICS
+
+// Statistics are computed from the answered Rounds of all Games, narrowed down by StatsFilter.
+// Wrong elimination is the elimination of the criminal - the investigator trusted the witness and the witness was wrong.
+
+const (
+	DefaultStatsLimit = 20
+	MaxStatsLimit     = 100
+)
+
+// Which Games are counted with StatsFilter.Simulated.
+const (
+	SimulatedExclude string = ""        // Only Games of players
+	SimulatedInclude string = "include" // Games of players and Simulations
+	SimulatedOnly    string = "only"    // Only Games played by Simulations, see Simulate()
+)
+
+// Which Rounds are counted in the statistics. Empty fields do not filter.
+type StatsFilter struct {
+	Model            string    // Model of the Game (the witness)
+	Service          string    // Service of the Model
+	Historical       bool      // Only Models shown in the historical statistics, see Model.Historical
+	From             time.Time // Rounds played at or after this time
+	To               time.Time // Rounds played before this time
+	Topic            string    // Topic of the Question
+	Simulated        string    // One of Simulated* constants, by default simulated Games are left out
+	DescriptionModel string    // Model of the Descriptions answered in the sweep, only for GetSweepBiasReports()
+	Limit            int       // Page size, 0 means DefaultStatsLimit, at most MaxStatsLimit
+	Offset           int
+}
+
+// One page of the statistics, Total is the number of items on all pages.
+type StatsPage[T any] struct {
+	Items  []T `json:"Items"`
+	Total  int `json:"Total"`
+	Limit  int `json:"Limit"`
+	Offset int `json:"Offset"`
+}
+
+// Suspect as the criminal of Investigations.
+type SuspectStats struct {
+	UUID              string `json:"UUID"`
+	Image             string `json:"Image"`
+	Investigations    int    `json:"Investigations"`    // Investigations in which the Suspect was the criminal
+	Rounds            int    `json:"Rounds"`            // Answered Rounds about the Suspect
+	YesAnswers        int    `json:"YesAnswers"`        // How many of the Rounds were answered YES
+	WrongEliminations int    `json:"WrongEliminations"` // Rounds in which the Suspect was eliminated
+}
+
+type QuestionStats struct {
+	UUID              string `json:"UUID"`
+	English           string `json:"English"`
+	Czech             string `json:"Czech"`
+	Polish            string `json:"Polish"`
+	Topic             string `json:"Topic"`
+	Rounds            int    `json:"Rounds"`            // Answered Rounds with the Question
+	WrongEliminations int    `json:"WrongEliminations"` // Rounds in which the criminal was eliminated after the Answer
+}
+
+type ModelStats struct {
+	Model             string `json:"Model"`
+	Service           string `json:"Service"`
+	Historical        bool   `json:"Historical"`
+	Games             int    `json:"Games"`
+	Rounds            int    `json:"Rounds"` // Answered Rounds
+	YesAnswers        int    `json:"YesAnswers"`
+	WrongEliminations int    `json:"WrongEliminations"` // Rounds in which the criminal was eliminated after the Answer
+}
+
+// Joins used by all statistics queries: r - rounds, i - investigations, g - games, m - models, qu - questions.
+const statsJoins = `FROM rounds r
+	JOIN investigations i ON i.uuid = r.investigation_uuid
+	JOIN games g ON g.uuid = i.game_uuid
+	LEFT JOIN models m ON m.Name = g.model
+	JOIN questions qu ON qu.UUID = r.question_uuid`
+
+// Whether the criminal was eliminated in the Round r, 1 or 0.
+const statsWrongElimination = `EXISTS (SELECT 1 FROM eliminations e WHERE e.RoundUUID = r.uuid AND e.SuspectUUID = i.criminal_uuid)`
+
+// WHERE clause of the filter for statsJoins and its arguments. Only Rounds answered by the Model are counted,
+// not those of ModeReverse answered by human witnesses.
+func (f StatsFilter) where() (string, []any) {
+	conditions := []string{"r.answer != ''", "g.mode != '" + ModeReverse + "'"}
+	var args []any
+	add := func(condition string, arg any) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+	if f.Model != "" {
+		add("g.model = ?", f.Model)
+	}
+	if f.Service != "" {
+		add("m.Service = ?", f.Service)
+	}
+	if f.Historical {
+		conditions = append(conditions, "m.Historical = 1")
+	}
+	// Timestamps have varying fraction width and UTC offset, so they are compared as times, not as text.
+	if !f.From.IsZero() {
+		add("julianday(r.timestamp) >= julianday(?)", f.From.UTC().Format(TimeFormat))
+	}
+	if !f.To.IsZero() {
+		add("julianday(r.timestamp) < julianday(?)", f.To.UTC().Format(TimeFormat))
+	}
+	if f.Topic != "" {
+		add("qu.Topic = ?", f.Topic)
+	}
+	switch f.Simulated {
+	case SimulatedExclude:
+		conditions = append(conditions, "g.simulation_uuid = ''")
+	case SimulatedOnly:
+		conditions = append(conditions, "g.simulation_uuid != ''")
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Page size and offset of the filter.
+func (f StatsFilter) page() (int, int) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultStatsLimit
+	}
+	return min(limit, MaxStatsLimit), max(f.Offset, 0)
+}
+
+// Query one page of the statistics and count the rows on all pages. Scan gets the destinations of the selected columns.
+func queryStatsPage[T any](f StatsFilter, query string, args []any, scan func(*T) []any) (StatsPage[T], error) {
+	result := StatsPage[T]{Items: []T{}}
+	result.Limit, result.Offset = f.page()
+	err := database.QueryRow("SELECT COUNT(*) FROM ("+query+")", args...).Scan(&result.Total)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err := database.Query(query+" LIMIT ? OFFSET ?", append(args, result.Limit, result.Offset)...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item T
+		if err := rows.Scan(scan(&item)...); err != nil {
+			return result, err
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result, rows.Err()
+}
+
+// Suspects who were the criminal in the filtered Rounds, most Investigations first.
+func GetSuspectStats(f StatsFilter) (StatsPage[SuspectStats], error) {
+	where, args := f.where()
+	query := fmt.Sprintf(`SELECT s.uuid, s.image, COUNT(DISTINCT i.uuid), COUNT(*),
+		SUM(r.answer = '%s'), SUM(%s)
+		%s
+		JOIN suspects s ON s.uuid = i.criminal_uuid
+		%s
+		GROUP BY s.uuid
+		ORDER BY COUNT(DISTINCT i.uuid) DESC, s.uuid`, VerdictYes, statsWrongElimination, statsJoins, where)
+	return queryStatsPage(f, query, args, func(s *SuspectStats) []any {
+		return []any{&s.UUID, &s.Image, &s.Investigations, &s.Rounds, &s.YesAnswers, &s.WrongEliminations}
+	})
+}
+
+// Suspects who were eliminated as the criminal most often.
+func GetConflictingSuspects(f StatsFilter) (StatsPage[SuspectStats], error) {
+	where, args := f.where()
+	query := fmt.Sprintf(`SELECT s.uuid, s.image, COUNT(DISTINCT i.uuid), COUNT(*),
+		SUM(r.answer = '%s'), SUM(%s) AS wrong
+		%s
+		JOIN suspects s ON s.uuid = i.criminal_uuid
+		%s
+		GROUP BY s.uuid
+		HAVING wrong > 0
+		ORDER BY wrong DESC, s.uuid`, VerdictYes, statsWrongElimination, statsJoins, where)
+	return queryStatsPage(f, query, args, func(s *SuspectStats) []any {
+		return []any{&s.UUID, &s.Image, &s.Investigations, &s.Rounds, &s.YesAnswers, &s.WrongEliminations}
+	})
+}
+
+// Questions after which the criminal was eliminated most often.
+func GetConflictingQuestions(f StatsFilter) (StatsPage[QuestionStats], error) {
+	where, args := f.where()
+	query := fmt.Sprintf(`SELECT qu.UUID, qu.English, qu.Czech, qu.Polish, qu.Topic, COUNT(*), SUM(%s) AS wrong
+		%s
+		%s
+		GROUP BY qu.UUID
+		HAVING wrong > 0
+		ORDER BY wrong DESC, qu.UUID`, statsWrongElimination, statsJoins, where)
+	return queryStatsPage(f, query, args, func(q *QuestionStats) []any {
+		return []any{&q.UUID, &q.English, &q.Czech, &q.Polish, &q.Topic, &q.Rounds, &q.WrongEliminations}
+	})
+}
+
+// Models which answered the filtered Rounds, most Rounds first. With StatsFilter.Historical
+// these are the Models of the historical statistics.
+func GetModelStats(f StatsFilter) (StatsPage[ModelStats], error) {
+	where, args := f.where()
+	query := fmt.Sprintf(`SELECT g.model, COALESCE(m.Service, ''), COALESCE(m.Historical, 0), COUNT(DISTINCT g.uuid), COUNT(*),
+		SUM(r.answer = '%s'), SUM(%s)
+		%s
+		%s
+		GROUP BY g.model
+		ORDER BY COUNT(*) DESC, g.model`, VerdictYes, statsWrongElimination, statsJoins, where)
+	return queryStatsPage(f, query, args, func(m *ModelStats) []any {
+		return []any{&m.Model, &m.Service, &m.Historical, &m.Games, &m.Rounds, &m.YesAnswers, &m.WrongEliminations}
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+)
+
+// MARK: SWEEP
+
+// Offline interrogation sweep: the Model answers every Question about every Suspect, once for each Description
+// of the Suspect, without any Game. Answers are saved in sweep_answers table, one per Model, Description and Question,
+// so the sweep can be stopped and run again - it continues with what is missing.
+type Sweep struct {
+	Model            string // Model which answers the Questions
+	DescriptionModel string // Model whose Descriptions of the Suspects are used, empty means Model
+	Topic            string // Only Questions of this Topic, empty means all
+	Concurrency      int    // How many answers are generated at once, at least 1
+	MaxAnswers       int    // Stop after generating this many answers, 0 means no limit
+	MaxTokens        int    // Stop after the answers used this many input and output tokens in total, 0 means no limit
+}
+
+// What the Sweep did in this run.
+type SweepSummary struct {
+	Total     int  `json:"Total"`     // Answers of the complete sweep
+	Skipped   int  `json:"Skipped"`   // Answers saved by previous runs
+	Generated int  `json:"Generated"` // Answers generated in this run
+	Tokens    int  `json:"Tokens"`    // Input and output tokens used in this run
+	Stopped   bool `json:"Stopped"`   // Run was stopped by MaxAnswers or MaxTokens before the sweep was complete
+}
+
+type sweepTask struct {
+	description Description
+	question    Question
+}
+
+// Run the Sweep, onAnswer is called after each saved Answer, possibly concurrently.
+// First failed answer stops the run, answers saved so far stay in the database.
+func RunSweep(s Sweep, onAnswer func(Answer)) (SweepSummary, error) {
+	var summary SweepSummary
+	if s.DescriptionModel == "" {
+		s.DescriptionModel = s.Model
+	}
+	service, err := GetServiceForModel(s.Model)
+	if err != nil {
+		return summary, fmt.Errorf("could not get service for model %s: %w", s.Model, err)
+	}
+	var topics []string
+	if s.Topic != "" {
+		topics = []string{s.Topic}
+	}
+	questions, err := getQuestions(database, topics, math.MaxInt32)
+	if err != nil {
+		return summary, err
+	}
+	suspects, err := GetAllSuspects()
+	if err != nil {
+		return summary, err
+	}
+
+	type taskKey struct{ description, question string }
+	done := make(map[taskKey]bool)
+	rows, err := database.Query("SELECT description_uuid, question_uuid FROM sweep_answers WHERE model = $1", s.Model)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key taskKey
+		if err := rows.Scan(&key.description, &key.question); err != nil {
+			return summary, err
+		}
+		done[key] = true
+	}
+	if err := rows.Err(); err != nil {
+		return summary, err
+	}
+
+	var tasks []sweepTask
+	for _, suspect := range suspects {
+		descriptions, err := GetDescriptionsForSuspect(suspect.UUID, s.DescriptionModel, true)
+		if err != nil {
+			return summary, err
+		}
+		if len(descriptions) == 0 {
+			log.Printf("Suspect %s has no description by %s, skipping\n", suspect.UUID, s.DescriptionModel)
+		}
+		for _, description := range descriptions {
+			for _, question := range questions {
+				summary.Total++
+				if done[taskKey{description.UUID, question.UUID}] {
+					summary.Skipped++
+					continue
+				}
+				tasks = append(tasks, sweepTask{description, question})
+			}
+		}
+	}
+	log.Printf("Sweep of %s: %d answers, %d done before, %d to generate\n", s.Model, summary.Total, summary.Skipped, len(tasks))
+
+	var mu sync.Mutex
+	var firstErr error
+	var started int
+	var wg sync.WaitGroup
+	// Caps are checked before each task is started, tasks which are already running are finished.
+	next := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr != nil {
+			return false
+		}
+		if (s.MaxAnswers > 0 && started >= s.MaxAnswers) || (s.MaxTokens > 0 && summary.Tokens >= s.MaxTokens) {
+			summary.Stopped = true
+			return false
+		}
+		started++
+		return true
+	}
+	queue := make(chan sweepTask)
+	for range max(s.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				answer, err := sweepAnswer(task, s.Model, service)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("sweep of question %s about description %s failed: %w", task.question.UUID, task.description.UUID, err)
+				}
+				if err == nil {
+					summary.Generated++
+					summary.Tokens += answer.InputTokens + answer.OutputTokens
+				}
+				mu.Unlock()
+				if err == nil && onAnswer != nil {
+					onAnswer(answer)
+				}
+			}
+		}()
+	}
+	for _, task := range tasks {
+		if !next() {
+			break
+		}
+		queue <- task
+	}
+	close(queue)
+	wg.Wait()
+	return summary, firstErr
+}
+
+// Generate the Answer of the Model to the Question about the Description and save it.
+func sweepAnswer(task sweepTask, model string, service Service) (Answer, error) {
+	answer, err := GenerateAnswer(task.question.English, task.description, model, service)
+	if err != nil {
+		return answer, err
+	}
+	query := `INSERT INTO sweep_answers (uuid, suspect_uuid, description_uuid, description_model, question_uuid, reflection, text, verdict,
+		attempts, reflection_prompt, decision_prompt, service, model, provider_model, latency_ms, input_tokens, output_tokens, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = database.Exec(query, answer.UUID, task.description.SuspectUUID, task.description.UUID, task.description.Model, task.question.UUID,
+		answer.Reflection, answer.Text, answer.Verdict, answer.Attempts, answer.ReflectionPrompt, answer.DecisionPrompt,
+		answer.Service, answer.Model, answer.ProviderModel, answer.LatencyMs, answer.InputTokens, answer.OutputTokens, answer.Timestamp)
+	if err != nil {
+		return answer, fmt.Errorf("could not save sweep answer: %w", err)
+	}
+	return answer, nil
+}
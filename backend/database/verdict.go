@@ -0,0 +1,113 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Phrases by which models usually refuse to answer. Checked on lowercased text.
+var refusalPhrases = []string{
+	"i can't",
+	"i can’t",
+	"i cannot",
+	"i'm sorry",
+	"i’m sorry",
+	"i am sorry",
+	"i'm unable",
+	"i’m unable",
+	"i am unable",
+	"i won't",
+	"i will not",
+	"not possible to determine",
+	"cannot be determined",
+	"can't be determined",
+	"impossible to say",
+	"as an ai",
+}
+
+var (
+	jsonObjectRegexp = regexp.MustCompile(`(?s)\{.*\}`)
+	wordRegexp       = regexp.MustCompile(`[A-Z]+`)
+)
+
+// Parse the decision of the model into VerdictYes, VerdictNo, VerdictRefused or VerdictUnknown.
+// Understands JSON like {"answer": "YES"} (also inside markdown code block), and free text
+// like "Yes.", "**NO** - because...", "Answer: yes". Text with a refusal phrase is VerdictRefused,
+// even if it mentions yes or no. If the text contains both YES and NO and does not start with one of them,
+// it is VerdictUnknown.
+func ParseVerdict(text string) string {
+	if verdict, ok := parseJSONVerdict(text); ok {
+		return verdict
+	}
+
+	words := wordRegexp.FindAllString(strings.ToUpper(text), -1)
+	if len(words) > 0 && (words[0] == VerdictYes || words[0] == VerdictNo) {
+		return words[0]
+	}
+	if len(words) > 1 && words[0] == "ANSWER" && (words[1] == VerdictYes || words[1] == VerdictNo) {
+		return words[1]
+	}
+
+	// Refusals often contain yes or no, like "there is no way to tell", so they are recognised first.
+	lower := strings.ToLower(text)
+	for _, phrase := range refusalPhrases {
+		if strings.Contains(lower, phrase) {
+			return VerdictRefused
+		}
+	}
+
+	var yes, no bool
+	for _, word := range words {
+		yes = yes || word == VerdictYes
+		no = no || word == VerdictNo
+	}
+	if yes && !no {
+		return VerdictYes
+	}
+	if no && !yes {
+		return VerdictNo
+	}
+	return VerdictUnknown
+}
+
+// Get the verdict from JSON object with key answer or verdict.
+func parseJSONVerdict(text string) (string, bool) {
+	object := jsonObjectRegexp.FindString(text)
+	if object == "" {
+		return "", false
+	}
+
+	var decision map[string]any
+	if err := json.Unmarshal([]byte(object), &decision); err != nil {
+		return "", false
+	}
+	for _, key := range []string{"answer", "verdict", "Answer", "Verdict"} {
+		value, ok := decision[key].(string)
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(strings.Trim(strings.TrimSpace(value), ".!")) {
+		case VerdictYes:
+			return VerdictYes, true
+		case VerdictNo:
+			return VerdictNo, true
+		case VerdictRefused:
+			return VerdictRefused, true
+		default:
+			return VerdictUnknown, true
+		}
+	}
+	return "", false
+}
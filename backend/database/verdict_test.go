@@ -0,0 +1,45 @@
+// Copyright (C) 2024 (Andreas Gajdosik) <andreas@gajdosik.org>
+// This file is part of project.
+//
+// project is non-violent software: you can use, redistribute,
+// and/or modify it under the terms of the CNPLv7+ as found
+// in the LICENSE file in the source code root directory or
+// at <https://git.pixie.town/thufie/npl-builder>.
+//
+// project comes with ABSOLUTELY NO WARRANTY, to the extent
+// permitted by applicable law. See the CNPL for details.
+
+package database
+
+import "testing"
+
+func TestParseVerdict(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"json answer", `{"answer": "YES"}`, VerdictYes},
+		{"json verdict lowercase", `{"verdict": "no."}`, VerdictNo},
+		{"json in code block", "```json\n{\"answer\": \"NO\"}\n```", VerdictNo},
+		{"json refused", `{"answer": "REFUSED"}`, VerdictRefused},
+		{"json unclear answer", `{"answer": "maybe"}`, VerdictUnknown},
+		{"plain yes", "Yes.", VerdictYes},
+		{"plain bold no with reason", "**NO** - the suspect wears no glasses.", VerdictNo},
+		{"answer prefix", "Answer: yes", VerdictYes},
+		{"only yes in text", "I would say yes, probably.", VerdictYes},
+		{"both yes and no", "It could be yes or no.", VerdictUnknown},
+		{"refusal", "I'm sorry, I cannot determine that from the description.", VerdictRefused},
+		{"refusal with no", "I'm sorry, there is no way to tell from the description.", VerdictRefused},
+		{"refusal with yes", "I cannot say yes to that from a description.", VerdictRefused},
+		{"unclear", "Hard to tell from this description.", VerdictUnknown},
+		{"empty", "", VerdictUnknown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ParseVerdict(test.text); got != test.want {
+				t.Errorf("ParseVerdict(%q) = %s, want %s", test.text, got, test.want)
+			}
+		})
+	}
+}
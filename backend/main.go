@@ -1,34 +1,67 @@
 package main
 
 import (
-	"encoding/binary"
+	"archive/zip"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/agajdosi/artificial_suspects/backend/database"
-	"github.com/google/uuid"
 )
 
+// How long can WaitForAnswerHandler() wait, reflection and decision retries of slow models take a while.
+const answerTimeout = 120 * time.Second
+
 func main() {
 	port := flag.String("port", "8080", "Port to run the server on")
 	host := flag.String("host", "localhost", "Host to run the server on, for production use 0.0.0.0")
 	db_path := flag.String("db-path", "./data/artsus.db", "Path to the database file")
+	answerWorkers := flag.Int("answer-workers", 4, "How many answers can be generated at once in the background")
+	flag.StringVar(&adminToken, "admin-token", os.Getenv("ARTSUS_ADMIN_TOKEN"), "Token for /admin endpoints, they are disabled when empty")
+	blocklist := flag.String("question-blocklist", "", "File with words not allowed in custom questions, one per line")
+	moderationModel := flag.String("moderation-model", "", "Model which classifies custom questions, no LLM moderation when empty")
 	flag.Parse()
 
 	err := database.EnsureDBAvailable(*db_path)
 	if err != nil {
 		log.Fatal(err)
 	}
+	database.StartAnswerWorkers(*answerWorkers)
+
+	moderators := database.DefaultQuestionModerators()
+	if *blocklist != "" {
+		moderator, err := database.LoadBlocklist(*blocklist)
+		if err != nil {
+			log.Fatal(err)
+		}
+		moderators = append(moderators, moderator)
+	}
+	if *moderationModel != "" {
+		moderators = append(moderators, database.LLMModerator{Model: *moderationModel})
+	}
+	database.SetQuestionModerators(moderators...)
 
 	mux := http.NewServeMux()
 	// gameplay
 	mux.HandleFunc("/new_game", enableCORS(NewGameHandler))
 	mux.HandleFunc("/get_game", enableCORS(GetGameHandler))
+	mux.HandleFunc("/games/{uuid}", enableCORS(GameHistoryHandler))
+	mux.HandleFunc("/reveal", enableCORS(RevealHandler))
 	mux.HandleFunc("/eliminate_suspect", enableCORS(EliminateSuspectHandler))
 	mux.HandleFunc("/next_round", enableCORS(NextRoundHandler))
+	mux.HandleFunc("/choose_question", enableCORS(ChooseQuestionHandler))
+	mux.HandleFunc("/ask_question", enableCORS(AskQuestionHandler))
+	mux.HandleFunc("/answer_question", enableCORS(AnswerQuestionHandler))
+	mux.HandleFunc("/investigate", enableCORS(InvestigateHandler))
 	mux.HandleFunc("/next_investigation", enableCORS(NextInvestigationHandler))
 	// scores
 	mux.HandleFunc("/get_scores", enableCORS(GetScoresHandler))
@@ -36,8 +69,23 @@ func main() {
 	// AI
 	mux.HandleFunc("/get_models", enableCORS(GetModelsHandler))
 	mux.HandleFunc("/get_or_generate_answer", enableCORS(GetOrGenerateAnswerHandler))
+	mux.HandleFunc("/get_answer", enableCORS(GetAnswerHandler))
+	mux.HandleFunc("/wait_for_answer", enableCORS(WaitForAnswerHandler))
+	mux.HandleFunc("/answer_events", enableCORS(AnswerEventsHandler))
+	// stats
+	mux.HandleFunc("/stats/suspects", enableCORS(statsHandler("SuspectStats", database.GetSuspectStats)))
+	mux.HandleFunc("/stats/conflicting_suspects", enableCORS(statsHandler("ConflictingSuspects", database.GetConflictingSuspects)))
+	mux.HandleFunc("/stats/conflicting_questions", enableCORS(statsHandler("ConflictingQuestions", database.GetConflictingQuestions)))
+	mux.HandleFunc("/stats/models", enableCORS(statsHandler("ModelStats", database.GetModelStats)))
+	mux.HandleFunc("/stats/bias", enableCORS(BiasReportHandler))
+	mux.HandleFunc("/stats/disagreement", enableCORS(DisagreementHandler))
 	// utils
 	mux.HandleFunc("/status", enableCORS(statusHandler))
+	// admin
+	mux.HandleFunc("/admin/regenerate_answer", requireAdmin(RegenerateAnswerHandler))
+	mux.HandleFunc("/admin/pending_questions", requireAdmin(PendingQuestionsHandler))
+	mux.HandleFunc("/admin/approve_question", requireAdmin(ApproveQuestionHandler))
+	mux.HandleFunc("/admin/export", requireAdmin(ExportHandler))
 
 	url := fmt.Sprintf("%s:%s", *host, *port)
 	log.Printf("🚀 Starting server on: http://%s", url)
@@ -63,12 +111,58 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// Respond with the error. Broken rule of the Game is sent as JSON with its own status,
+// so frontend can tell the player what went wrong. Other errors are internal.
+func respondError(w http.ResponseWriter, err error) {
+	var ruleErr *database.RuleError
+	if !errors.As(err, &ruleErr) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(ruleErr)
+	if err != nil {
+		log.Printf("respondError() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(ruleErr.Status)
+	w.Write(resp)
+}
+
+// Token which has to be sent as Bearer token to /admin endpoints.
+var adminToken string
+
+// Admin middleware, allows only requests with Authorization header carrying the adminToken.
+// Admin endpoints are not for the frontend, so there is no CORS. Their handlers must not log the whole request,
+// it would write the token from the header into the log.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			log.Printf("Admin endpoint %s called, but admin token is not set", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			log.Printf("Unauthorized request to admin endpoint %s", r.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
 func statusHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🔍 statusHandler() request: %v", r)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
 
+// Create new game for the player identified by query parameter player_uuid, played with required query parameter model.
+// Optional query parameter suspects sets how many suspects are in each investigation, by default database.DefaultSuspects.
+// Optional query parameter mode selects the database.GameMode, by default classic.
 func NewGameHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🎮 NewGameHandler() request: %v", r)
 	playerUUID := r.URL.Query().Get("player_uuid")
@@ -81,8 +175,25 @@ func NewGameHandler(w http.ResponseWriter, r *http.Request) {
 	if playerUUID == "" {
 		log.Println("NewGameHandler() warning: player_uuid is empty! Creating new game without player.UUID.")
 	}
+	suspects := 0 // database.DefaultSuspects
+	if r.URL.Query().Get("suspects") != "" {
+		var err error
+		suspects, err = strconv.Atoi(r.URL.Query().Get("suspects"))
+		if err != nil || suspects < database.MinSuspects {
+			log.Printf("NewGameHandler() error: query parameter 'suspects' must be a number >= %d!", database.MinSuspects)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if _, err := database.GetGameMode(mode); err != nil {
+		log.Printf("NewGameHandler() error: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-	game, err := database.NewGame(playerUUID, model)
+	game, err := database.NewGame(playerUUID, model, suspects, mode)
 	if err != nil {
 		log.Printf("NewGame() error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -102,6 +213,68 @@ func NewGameHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(resp)
 }
 
+// Get the whole history of the Game identified by its UUID in the path: all Investigations with their Rounds,
+// Answers and Eliminations. Criminal and whole Answers are revealed only for finished Investigations.
+func GameHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("📜 GameHistoryHandler() request: %v", r)
+	gameUUID := r.PathValue("uuid")
+
+	game, err := database.GetGame(gameUUID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("GetGame() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(game)
+	if err != nil {
+		log.Printf("GameHistoryHandler() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// Reveal the criminal, the Description the witness was reasoning from and its reflections of every Round
+// of the finished Investigation identified by required query parameter investigation_uuid.
+// Responds 409 while the Investigation is still in progress.
+func RevealHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("🎭 RevealHandler() request: %v", r)
+	investigationUUID := r.URL.Query().Get("investigation_uuid")
+	if investigationUUID == "" {
+		log.Printf("RevealHandler() error: investigation_uuid is empty!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reveal, err := database.GetReveal(investigationUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("GetReveal() error: %v", err)
+		respondError(w, err)
+		return
+	}
+
+	resp, err := json.Marshal(reveal)
+	if err != nil {
+		log.Printf("RevealHandler() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
 // Get the current game for the current player identified by required query parameter player_uuid.
 func GetGameHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🔍 GetGameHandler() request: %v", r)
@@ -147,10 +320,10 @@ func NextInvestigationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	game.Investigation, err = database.NewInvestigation(game.UUID)
+	game.Investigation, err = database.NextInvestigation(game.UUID, game.Suspects)
 	if err != nil {
 		log.Printf("NextInvestigationHandler() error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondError(w, err)
 		return
 	}
 	game.Level, err = database.GetLevel(game.UUID)
@@ -188,10 +361,10 @@ func NextRoundHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	round, err := database.NewRound(game.Investigation.UUID)
+	round, err := database.NextRound(game.Investigation.UUID)
 	if err != nil {
 		log.Printf("NextRound() error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondError(w, err)
 		return
 	}
 	game.Investigation.Rounds = append(game.Investigation.Rounds, round) // prepend
@@ -228,34 +401,145 @@ func GetScoresHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(resp)
 }
 
-// Based on the UUID (of the current investigation) choose the index (of description) to be used.
-// Be consistent across the one UUID, the investigation yet choose differently on next UUID (of investigation).
-func randomForThisInvestigation(UUID string, choices int) int {
-	if choices <= 0 {
-		return 0
+// Choose the Question of the Round from the Questions offered in ModeChoose, witness starts answering afterwards.
+// Requires query parameters round_uuid and question_uuid, responds with the Round.
+func ChooseQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("🃏 ChooseQuestionHandler() request: %v", r)
+	roundUUID := r.URL.Query().Get("round_uuid")
+	questionUUID := r.URL.Query().Get("question_uuid")
+	if roundUUID == "" || questionUUID == "" {
+		log.Printf("ChooseQuestion() error: round_uuid and question_uuid are required!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
-	id, err := uuid.Parse(UUID)
+
+	round, err := database.ChooseQuestion(roundUUID, questionUUID)
 	if err != nil {
-		fmt.Printf("Error parsing UUID %s: %v", UUID, err)
-		return 0
+		log.Printf("ChooseQuestion() error: %v", err)
+		respondError(w, err)
+		return
 	}
 
-	pseudoRandom := binary.BigEndian.Uint64(id[0:8])
-	num := int(pseudoRandom % uint64(choices))
+	resp, err := json.Marshal(round)
+	if err != nil {
+		log.Printf("ChooseQuestion() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-	return num
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
 }
 
+// Ask own Question in the Round instead of choosing one of the offered, in game modes which allow custom questions.
+// Requires query parameters round_uuid and question - the text of the Question, responds with the Round.
+// Question which does not pass the moderation is refused with 422 and the reason in the message.
+func AskQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("✍️ AskQuestionHandler() request: %v", r)
+	roundUUID := r.URL.Query().Get("round_uuid")
+	question := r.URL.Query().Get("question")
+	if roundUUID == "" || question == "" {
+		log.Printf("AskQuestion() error: round_uuid and question are required!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	round, err := database.AskQuestion(roundUUID, question)
+	if err != nil {
+		log.Printf("AskQuestion() error: %v", err)
+		respondError(w, err)
+		return
+	}
+
+	resp, err := json.Marshal(round)
+	if err != nil {
+		log.Printf("AskQuestion() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// Answer the Question of the Round as the witness in reverse mode, then the AI investigator eliminates.
+// Requires query parameters round_uuid and answer (YES or NO), responds with the decision of the investigator.
+// If the investigator fails, the answer stays saved and the decision can be retried by InvestigateHandler().
+func AnswerQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("🗣️ AnswerQuestionHandler() request: %v", r)
+	roundUUID := r.URL.Query().Get("round_uuid")
+	verdict := r.URL.Query().Get("answer")
+	if roundUUID == "" || verdict == "" {
+		log.Printf("AnswerQuestion() error: round_uuid and answer are required!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	_, err := database.AnswerQuestion(roundUUID, verdict)
+	if err != nil {
+		log.Printf("AnswerQuestion() error: %v", err)
+		respondError(w, err)
+		return
+	}
+	investigate(w, roundUUID)
+}
+
+// Let the AI investigator eliminate Suspects of the Round identified by query parameter round_uuid, in reverse mode.
+// Decision is made only once, repeated calls just finish its Eliminations and respond with it.
+func InvestigateHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("🕵️ InvestigateHandler() request: %v", r)
+	roundUUID := r.URL.Query().Get("round_uuid")
+	if roundUUID == "" {
+		log.Printf("InvestigateHandler() error: round_uuid is empty!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	investigate(w, roundUUID)
+}
+
+func investigate(w http.ResponseWriter, roundUUID string) {
+	decision, err := database.InvestigateRound(roundUUID)
+	var ruleErr *database.RuleError
+	if errors.As(err, &ruleErr) {
+		respondError(w, err)
+		return
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Error deciding eliminations: %v", err)
+		log.Printf("InvestigateRound(): %v\n", errMsg)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte(errMsg))
+		return
+	}
+
+	resp, err := json.Marshal(decision)
+	if err != nil {
+		log.Printf("InvestigateRound() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// Eliminate the Suspect identified by query parameter suspect_uuid in the current Round (round_uuid)
+// of the current Investigation (investigation_uuid). Responds 409 or 422 if the rules do not allow it.
 func EliminateSuspectHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🎯 EliminateSuspectHandler() request: %v", r)
 	suspectUUID := r.URL.Query().Get("suspect_uuid")
 	roundUUID := r.URL.Query().Get("round_uuid")
 	investigationUUID := r.URL.Query().Get("investigation_uuid")
+	if suspectUUID == "" || roundUUID == "" || investigationUUID == "" {
+		log.Printf("EliminateSuspect() error: suspect_uuid, round_uuid and investigation_uuid are required!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
 	err := database.SaveElimination(suspectUUID, roundUUID, investigationUUID)
 	if err != nil {
 		log.Printf("EliminateSuspect() error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondError(w, err)
 		return
 	}
 
@@ -307,9 +591,9 @@ func GetModelsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(resp)
 }
 
-// TODO: toto muzeme vlastne oddelat
-// 1. generovat answer z newGame anebo z nextRound primo v Gocku
-// 2. na frontend pak jen pockat skrze WaitForAnswer
+// Get the Answer for the last Round of the current Game of the player identified by required query parameter player_uuid.
+// Answer is generated only if the Round has none yet, concurrent requests share one generation.
+// Answers are normally generated in the background when the Round is created, use WaitForAnswerHandler() to get them.
 func GetOrGenerateAnswerHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("🔍 GetOrGenerateAnswerHandler() request: %v", r)
 	playerUUID := r.URL.Query().Get("player_uuid")
@@ -321,7 +605,6 @@ func GetOrGenerateAnswerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	game, err := database.GetCurrentGame(playerUUID)
-	question := game.Investigation.Rounds[len(game.Investigation.Rounds)-1].Question.English
 	if err != nil {
 		errMsg := fmt.Sprintf("Error getting currentGame for player_uuid %s: %v", playerUUID, err)
 		log.Printf("GetOrGenerateAnswerHandler(): %v\n", errMsg)
@@ -330,67 +613,461 @@ func GetOrGenerateAnswerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("===> game.Model: %s\n", game.Model)
-
-	// TODO: get the service based on the current game's Model
-	service, err := database.GetServiceForModel(game.Model)
+	roundUUID := game.Investigation.Rounds[len(game.Investigation.Rounds)-1].UUID
+	answer, err := database.GetOrGenerateAnswer(roundUUID)
 	if err != nil {
-		errMsg := fmt.Sprintf("Error getting service for model %s: %v", game.Model, err)
+		errMsg := fmt.Sprintf("Error generating answer: %v", err)
 		log.Printf("GetOrGenerateAnswerHandler(): %v\n", errMsg)
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusTeapot)
 		w.Write([]byte(errMsg))
 		return
 	}
 
-	descriptions, err := database.GetDescriptionsForSuspect(
-		game.Investigation.CriminalUUID,
-		game.Model,
-		false, // do not be strict, allow fallback to any description
-	)
+	log.Printf("GetOrGenerateAnswerHandler() - answer: %s", answer.Text)
+
+	resp, err := json.Marshal(answer.Public())
 	if err != nil {
-		errMsg := fmt.Sprintf("Error getting descriptions for suspect: %v", err)
+		errMsg := fmt.Sprintf("Error marshalling answer: %v", err)
 		log.Printf("GetOrGenerateAnswerHandler(): %v\n", errMsg)
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(errMsg))
 		return
 	}
 
-	x := randomForThisInvestigation(game.Investigation.UUID, len(descriptions))
-	answer, err := database.GenerateAnswer(question, descriptions[x].Description, game.Model, service)
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// Get the Answer of the Round identified by required query parameter round_uuid.
+// Reflection, prompts and Description are included only once the Investigation is finished,
+// before that they would reveal too much about the criminal.
+func GetAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("🔍 GetAnswerHandler() request: %v", r)
+	roundUUID := r.URL.Query().Get("round_uuid")
+	if roundUUID == "" {
+		log.Printf("GetAnswerHandler() error: round_uuid is empty!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	answer, err := database.GetAnswerForRound(roundUUID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("GetAnswerForRound() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	investigationUUID, err := database.GetRoundInvestigationUUID(roundUUID)
+	if err != nil {
+		log.Printf("GetRoundInvestigationUUID() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	finished, err := database.IsInvestigationFinished(investigationUUID)
+	if err != nil {
+		log.Printf("IsInvestigationFinished() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !finished {
+		answer = answer.Public()
+	}
+
+	resp, err := json.Marshal(answer)
+	if err != nil {
+		log.Printf("GetAnswerHandler() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// Wait for the Answer of the Round identified by required query parameter round_uuid, which is generated in the background.
+// If the Answer is neither saved nor pending (e.g. its generation failed before), it is queued again.
+// Responds 418 if the generation failed and 504 if it did not finish in time.
+func WaitForAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("⏳ WaitForAnswerHandler() request: %v", r)
+	roundUUID := r.URL.Query().Get("round_uuid")
+	if roundUUID == "" {
+		log.Printf("WaitForAnswerHandler() error: round_uuid is empty!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	_, err := database.GetAnswerForRound(roundUUID)
+	if err == sql.ErrNoRows && !database.IsAnswerPending(roundUUID) {
+		err = database.EnqueueAnswer(roundUUID)
+		if err != nil {
+			log.Printf("WaitForAnswerHandler() could not queue answer: %v", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	answer, err := database.WaitForAnswer(roundUUID, answerTimeout)
+	if err == database.ErrAnswerTimeout {
+		log.Printf("WaitForAnswerHandler() error: %v", err)
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
 	if err != nil {
 		errMsg := fmt.Sprintf("Error generating answer: %v", err)
-		log.Printf("GetOrGenerateAnswerHandler(): %v\n", errMsg)
+		log.Printf("WaitForAnswerHandler(): %v\n", errMsg)
 		w.WriteHeader(http.StatusTeapot)
 		w.Write([]byte(errMsg))
 		return
 	}
 
-	// TODO: move to database.GenerateAnswer()?
-	err = database.SaveAnswer(answer, game.Investigation.Rounds[len(game.Investigation.Rounds)-1].UUID)
+	resp, err := json.Marshal(answer.Public())
 	if err != nil {
-		errMsg := fmt.Sprintf("Error saving answer: %v", err)
-		log.Printf("GetOrGenerateAnswerHandler(): %v\n", errMsg)
+		log.Printf("WaitForAnswerHandler() error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// Stream the progress of the Answer generation for the Round identified by required query parameter round_uuid
+// as Server-Sent Events, see database.AnswerEvent for the event types. Stream ends with verdict or error event.
+// If the Answer already exists, only the verdict event is sent.
+func AnswerEventsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("📡 AnswerEventsHandler() request: %v", r)
+	roundUUID := r.URL.Query().Get("round_uuid")
+	if roundUUID == "" {
+		log.Printf("AnswerEventsHandler() error: round_uuid is empty!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Printf("AnswerEventsHandler() error: streaming not supported!")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before looking for the Answer, so the verdict cannot slip in between.
+	events, unsubscribe := database.SubscribeAnswerEvents(roundUUID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	answer, err := database.GetAnswerForRound(roundUUID)
+	if err == nil {
+		public := answer.Public()
+		writeSSE(w, database.AnswerEvent{Type: database.EventVerdict, RoundUUID: roundUUID, Answer: &public})
+		flusher.Flush()
+		return
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("AnswerEventsHandler() error: %v", err)
+		writeSSE(w, database.AnswerEvent{Type: database.EventError, RoundUUID: roundUUID, Text: err.Error()})
+		flusher.Flush()
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event := <-events:
+			writeSSE(w, event)
+			flusher.Flush()
+			if event.Final() {
+				return
+			}
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event database.AnswerEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("writeSSE() error: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
+
+// Generate new Answer for the Round identified by required query parameter round_uuid, even if it already has one.
+// Previous Answers are kept in the database. Responds with the whole new Answer.
+func RegenerateAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("🔁 RegenerateAnswerHandler() request: %s %s", r.Method, r.URL.Path)
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	roundUUID := r.URL.Query().Get("round_uuid")
+	if roundUUID == "" {
+		log.Printf("RegenerateAnswerHandler() error: round_uuid is empty!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := database.GetRoundInvestigationUUID(roundUUID); err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	answer, err := database.RegenerateAnswer(roundUUID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error generating answer: %v", err)
+		log.Printf("RegenerateAnswerHandler(): %v\n", errMsg)
+		w.WriteHeader(http.StatusTeapot)
 		w.Write([]byte(errMsg))
 		return
 	}
 
-	log.Printf("GetOrGenerateAnswerHandler() - generated answer: %s", answer)
+	resp, err := json.Marshal(answer)
+	if err != nil {
+		log.Printf("RegenerateAnswerHandler() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-	resp, err := json.Marshal(
-		database.Answer{ // TODO: add UUID and Timestamp once Answer has its own table
-			UUID:      "",
-			Text:      answer,
-			Timestamp: "",
-		})
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// List custom questions written by players which wait for approval.
+func PendingQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("🔍 PendingQuestionsHandler() request: %s %s", r.Method, r.URL.Path)
+	questions, err := database.GetPendingQuestions()
 	if err != nil {
-		errMsg := fmt.Sprintf("Error marshalling answer: %v", err)
-		log.Printf("GetOrGenerateAnswerHandler(): %v\n", errMsg)
+		log.Printf("GetPendingQuestions() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(questions)
+	if err != nil {
+		log.Printf("PendingQuestionsHandler() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// Handler of the /stats endpoint responding with one database.StatsPage of the statistics by get.
+// Optional query parameters: model, service, historical=true, from and to (RFC 3339 or 2006-01-02), topic, limit and offset.
+// Simulated games are counted with simulated=include, or only them with simulated=only.
+func statsHandler[T any](name string, get func(database.StatsFilter) (database.StatsPage[T], error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("📊 %sHandler() request: %v", name, r)
+		filter, err := parseStatsFilter(r)
+		if err != nil {
+			log.Printf("%sHandler() error: %v", name, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		page, err := get(filter)
+		if err != nil {
+			log.Printf("Get%s() error: %v", name, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := json.Marshal(page)
+		if err != nil {
+			log.Printf("%sHandler() error: %v", name, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	}
+}
+
+// Respond with database.BiasReport of each model, filtered like in statsHandler() without pagination.
+// Optional query parameter min_answers leaves out the cells with fewer answers, by default 1.
+func BiasReportHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("📊 BiasReportHandler() request: %v", r)
+	filter, err := parseStatsFilter(r)
+	if err != nil {
+		log.Printf("BiasReportHandler() error: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	minAnswers := 1
+	if r.URL.Query().Get("min_answers") != "" {
+		minAnswers, err = strconv.Atoi(r.URL.Query().Get("min_answers"))
+		if err != nil {
+			log.Printf("BiasReportHandler() error: query parameter 'min_answers' must be a number!")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	reports, err := database.GetBiasReports(filter, minAnswers)
+	if err != nil {
+		log.Printf("GetBiasReports() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(reports)
+	if err != nil {
+		log.Printf("BiasReportHandler() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// Respond with database.DisagreementReport of the models, filtered like in statsHandler().
+// Query parameter limit sets how many most contested suspects and questions are listed.
+func DisagreementHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("📊 DisagreementHandler() request: %v", r)
+	filter, err := parseStatsFilter(r)
+	if err != nil {
+		log.Printf("DisagreementHandler() error: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	report, err := database.GetDisagreementReport(filter)
+	if err != nil {
+		log.Printf("GetDisagreementReport() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("DisagreementHandler() error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(errMsg))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(resp)
 }
+
+func parseStatsFilter(r *http.Request) (database.StatsFilter, error) {
+	query := r.URL.Query()
+	filter := database.StatsFilter{
+		Model:      query.Get("model"),
+		Service:    query.Get("service"),
+		Historical: query.Get("historical") == "true",
+		Topic:      query.Get("topic"),
+		Simulated:  query.Get("simulated"),
+	}
+	switch filter.Simulated {
+	case database.SimulatedExclude, database.SimulatedInclude, database.SimulatedOnly:
+	default:
+		return filter, fmt.Errorf("query parameter 'simulated' must be '%s' or '%s'", database.SimulatedInclude, database.SimulatedOnly)
+	}
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		// Dates are midnights of the server's time zone, like the timestamps of the Rounds.
+		t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return filter, fmt.Errorf("query parameter '%s' must be RFC 3339 timestamp or date 2006-01-02", param.name)
+			}
+		}
+		*param.dest = t
+	}
+	for _, param := range []struct {
+		name string
+		dest *int
+	}{{"limit", &filter.Limit}, {"offset", &filter.Offset}} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return filter, fmt.Errorf("query parameter '%s' must be a number >= 0", param.name)
+		}
+		*param.dest = number
+	}
+	return filter, nil
+}
+
+// Approve the custom question identified by query parameter question_uuid, so it can be selected for any game.
+func ApproveQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("✅ ApproveQuestionHandler() request: %s %s", r.Method, r.URL.Path)
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	questionUUID := r.URL.Query().Get("question_uuid")
+	if questionUUID == "" {
+		log.Printf("ApproveQuestionHandler() error: question_uuid is empty!")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err := database.ApproveQuestion(questionUUID)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("ApproveQuestion() error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Respond with ZIP archive of the dataset export, see database.ExportDataset().
+// Optional query parameter format (jsonl or csv) can be repeated, by default both. Optional header X-Export-Salt
+// keeps the pseudonyms of players the same across exports, by default they are random. Salt is a secret,
+// it is not accepted in the URL which ends up in logs.
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("📦 ExportHandler() request: %s %s", r.Method, r.URL.Path)
+	formats := r.URL.Query()["format"]
+	if len(formats) == 0 {
+		formats = []string{database.ExportJSONL, database.ExportCSV}
+	}
+	for _, format := range formats {
+		if format != database.ExportJSONL && format != database.ExportCSV {
+			log.Printf("ExportHandler() error: unknown format %q!", format)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Archive is streamed, so errors after the first file cannot change the status - the archive stays incomplete.
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"artsus-export-%s.zip\"", time.Now().Format("20060102-150405")))
+	archive := zip.NewWriter(w)
+	if _, err := database.ExportDataset(archive, formats, r.Header.Get("X-Export-Salt")); err != nil {
+		log.Printf("ExportDataset() error: %v", err)
+		return
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("ExportHandler() error: %v", err)
+	}
+}
@@ -14,11 +14,14 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/agajdosi/artificial_suspects/backend/database"
 	"github.com/urfave/cli/v2"
@@ -68,6 +71,155 @@ func main() {
 				Usage:   "import images from ./src/input",
 				Action:  renameToSha256,
 			},
+			{
+				Name:  "simulate",
+				Usage: "Play games headlessly, AI witness against AI or rule-based investigator.",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:     "pair",
+						Usage:    "Models as witness=investigator, investigator can be \"random\", repeat for more pairs",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  "games",
+						Usage: "Number of games for each pair",
+						Value: 1,
+					},
+					&cli.Uint64Flag{
+						Name:  "seed",
+						Usage: "Seed of the suspects, criminals, questions and the rule-based investigator",
+						Value: 1,
+					},
+					&cli.IntFlag{
+						Name:  "suspects",
+						Usage: "Number of suspects in each investigation, 0 for default",
+					},
+					&cli.IntFlag{
+						Name:  "max-investigations",
+						Usage: "Stop the game after this many investigations, 0 for no limit",
+						Value: 5,
+					},
+				},
+				Action: simulate,
+			},
+			{
+				Name:  "report",
+				Usage: "Print the bias report - YES-rate of each model by suspect and question or topic.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "model",
+						Usage: "Only report this model",
+					},
+					&cli.StringFlag{
+						Name:  "topic",
+						Usage: "Only report questions of this topic",
+					},
+					&cli.BoolFlag{
+						Name:  "by-topic",
+						Usage: "Aggregate the questions by their topic",
+					},
+					&cli.IntFlag{
+						Name:  "min-answers",
+						Usage: "Leave out suspect and question pairs with fewer answers",
+						Value: 1,
+					},
+					&cli.BoolFlag{
+						Name:  "sweep",
+						Usage: "Report the answers of the sweep command instead of the games",
+					},
+					&cli.StringFlag{
+						Name:  "description-model",
+						Usage: "With --sweep, only the answers about descriptions by this model",
+					},
+					&cli.StringFlag{
+						Name:  "simulated",
+						Usage: "Count also simulated games with \"include\", or only them with \"only\"",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the report as JSON",
+					},
+				},
+				Action: report,
+			},
+			{
+				Name:  "disagreement",
+				Usage: "Print how much the models agree on the answers and the most contested suspects and questions.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "topic",
+						Usage: "Only compare questions of this topic",
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "Number of most contested suspects and questions to print",
+						Value: 10,
+					},
+					&cli.StringFlag{
+						Name:  "simulated",
+						Usage: "Count also simulated games with \"include\", or only them with \"only\"",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the report as JSON",
+					},
+				},
+				Action: disagreement,
+			},
+			{
+				Name:  "sweep",
+				Usage: "Ask every question about every suspect, once for each description. Run again to continue.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "model",
+						Usage:    "Model which answers the questions",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "description-model",
+						Usage: "Model whose descriptions of the suspects are used, by default the answering model",
+					},
+					&cli.StringFlag{
+						Name:  "topic",
+						Usage: "Only ask questions of this topic",
+					},
+					&cli.IntFlag{
+						Name:  "concurrency",
+						Usage: "How many answers are generated at once",
+						Value: 4,
+					},
+					&cli.IntFlag{
+						Name:  "max-answers",
+						Usage: "Stop after generating this many answers, 0 for no limit",
+					},
+					&cli.IntFlag{
+						Name:  "max-tokens",
+						Usage: "Stop after using this many input and output tokens, 0 for no limit",
+					},
+				},
+				Action: sweep,
+			},
+			{
+				Name:  "export",
+				Usage: "Export the dataset as JSONL and CSV files with anonymised players.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "dir",
+						Usage: "Directory for the exported files",
+						Value: "./export",
+					},
+					&cli.StringSliceFlag{
+						Name:  "format",
+						Usage: "Format of the files, jsonl or csv, repeat for more",
+						Value: cli.NewStringSlice(database.ExportJSONL, database.ExportCSV),
+					},
+					&cli.StringFlag{
+						Name:  "salt",
+						Usage: "Secret salt of player pseudonyms, use the same to join exports, random by default",
+					},
+				},
+				Action: export,
+			},
 		},
 	}
 
@@ -167,3 +319,138 @@ func describeAll(cCtx *cli.Context) error {
 	limit := cCtx.Int("limit")
 	return database.GenerateDescriptionsForAllSuspects(modelName, limit)
 }
+
+func simulate(cCtx *cli.Context) error {
+	for _, pair := range cCtx.StringSlice("pair") {
+		witness, investigator, ok := strings.Cut(pair, "=")
+		if !ok || witness == "" || investigator == "" {
+			return fmt.Errorf("pair %q must be witness=investigator", pair)
+		}
+		simulation := database.Simulation{
+			Witness:           witness,
+			Investigator:      investigator,
+			Seed:              cCtx.Uint64("seed"),
+			Games:             cCtx.Int("games"),
+			Suspects:          cCtx.Int("suspects"),
+			MaxInvestigations: cCtx.Int("max-investigations"),
+		}
+		var rounds, score int
+		simulation, err := database.Simulate(simulation, func(game database.SimulatedGame) {
+			rounds += game.Rounds
+			score += game.Score
+			fmt.Printf("%s vs %s: game %s %s after %d investigations and %d rounds, score %d\n",
+				witness, investigator, game.GameUUID, game.Status, game.Investigations, game.Rounds, game.Score)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Simulation %s (%s vs %s): %d games, %d rounds, total score %d\n",
+			simulation.UUID, witness, investigator, simulation.Games, rounds, score)
+	}
+	return nil
+}
+
+func report(cCtx *cli.Context) error {
+	filter := database.StatsFilter{Model: cCtx.String("model"), Topic: cCtx.String("topic"), Simulated: cCtx.String("simulated"),
+		DescriptionModel: cCtx.String("description-model")}
+	getReports := database.GetBiasReports
+	if cCtx.Bool("sweep") {
+		getReports = database.GetSweepBiasReports
+	}
+	reports, err := getReports(filter, cCtx.Int("min-answers"))
+	if err != nil {
+		return err
+	}
+	if cCtx.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+
+	for _, r := range reports {
+		fmt.Printf("\n%s (%s), %d answers\n", r.Model, r.Service, r.Answers)
+		if r.DescriptionModel != "" {
+			fmt.Printf("about descriptions by %s\n", r.DescriptionModel)
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		cells := r.Questions
+		if cCtx.Bool("by-topic") {
+			cells = r.Topics
+		}
+		fmt.Fprintln(writer, "SUSPECT\tTOPIC\tQUESTION\tANSWERS\tYES\tYES-RATE\t95% CI")
+		for _, c := range cells {
+			fmt.Fprintf(writer, "%.8s\t%s\t%s\t%d\t%d\t%.2f\t%.2f-%.2f\n",
+				c.SuspectUUID, c.Topic, c.Question, c.Answers, c.Yes, c.YesRate, c.Low, c.High)
+		}
+		writer.Flush()
+	}
+	return nil
+}
+
+func disagreement(cCtx *cli.Context) error {
+	filter := database.StatsFilter{Topic: cCtx.String("topic"), Limit: cCtx.Int("limit"), Simulated: cCtx.String("simulated")}
+	report, err := database.GetDisagreementReport(filter)
+	if err != nil {
+		return err
+	}
+	if cCtx.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	fmt.Printf("Models: %s\n", strings.Join(report.Models, ", "))
+	fmt.Printf("Suspect/question pairs answered by 2+ models: %d\n", report.Items)
+	fmt.Printf("Fleiss' kappa: %.3f, disagreement rate: %.3f\n", report.FleissKappa, report.Disagreement)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "\nMODEL A\tMODEL B\tPAIRS\tAGREEMENT\tCOHEN'S KAPPA")
+	for _, p := range report.Pairs {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%.3f\t%.3f\n", p.ModelA, p.ModelB, p.Items, p.Agreement, p.CohenKappa)
+	}
+	fmt.Fprintln(writer, "\nSUSPECT\tPAIRS\tDISAGREEMENT")
+	for _, c := range report.Suspects {
+		fmt.Fprintf(writer, "%s\t%d\t%.3f\n", c.Label, c.Items, c.Disagreement)
+	}
+	fmt.Fprintln(writer, "\nQUESTION\tPAIRS\tDISAGREEMENT")
+	for _, c := range report.Questions {
+		fmt.Fprintf(writer, "%s\t%d\t%.3f\n", c.Label, c.Items, c.Disagreement)
+	}
+	return writer.Flush()
+}
+
+func sweep(cCtx *cli.Context) error {
+	s := database.Sweep{
+		Model:            cCtx.String("model"),
+		DescriptionModel: cCtx.String("description-model"),
+		Topic:            cCtx.String("topic"),
+		Concurrency:      cCtx.Int("concurrency"),
+		MaxAnswers:       cCtx.Int("max-answers"),
+		MaxTokens:        cCtx.Int("max-tokens"),
+	}
+	summary, err := database.RunSweep(s, func(answer database.Answer) {
+		fmt.Printf("%s: %s (description %s)\n", answer.Model, answer.Verdict, answer.DescriptionUUID)
+	})
+	fmt.Printf("Sweep of %s: %d answers in total, %d done before, %d generated now using %d tokens\n",
+		s.Model, summary.Total, summary.Skipped, summary.Generated, summary.Tokens)
+	if summary.Stopped {
+		fmt.Println("Stopped by the limit, run again to continue.")
+	}
+	return err
+}
+
+func export(cCtx *cli.Context) error {
+	dir := &database.ExportDir{Path: cCtx.String("dir")}
+	manifest, err := database.ExportDataset(dir, cCtx.StringSlice("format"), cCtx.String("salt"))
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range manifest.Files {
+		fmt.Printf("%s: %d rows\n", filepath.Join(dir.Path, file.Name), file.Rows)
+	}
+	fmt.Printf("Exported schema version %d at %s\n", manifest.SchemaVersion, manifest.ExportedAt)
+	return nil
+}
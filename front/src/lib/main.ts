@@ -22,6 +22,7 @@ const initPOST = {
 export interface Answer {
     UUID: string;
     Text: string;
+    Verdict?: string; // YES, NO or REFUSED
     Timestamp: string;
 }
 
@@ -56,10 +57,14 @@ export interface Game {
     investigation: Investigation;
     level: number;
     Score: number;
+    Status: 'awaiting_question' | 'awaiting_answer' | 'awaiting_elimination' | 'investigation_solved' | 'game_over';
+    Mode?: string; // how the game is played, 'classic' by default
+    Simulation?: string; // UUID of the simulation which played the game, only for self-play games
     GameOver: boolean;
     Investigator: string;
     Model: string;
     Timestamp: string;
+    investigations?: Investigation[]; // whole history, only from /games/{uuid}
 }
 
 export interface Investigation {
@@ -69,6 +74,9 @@ export interface Investigation {
     rounds: Round[];
     CriminalUUID: string;
     InvestigationOver: boolean;
+    CriminalReleased?: boolean;
+    DescriptionFallback?: boolean; // witness uses description by other model than the one of the game
+    Criminal?: Suspect; // revealed once the investigation is over (only from /games/{uuid}), or to the witness in "reverse" mode
     Timestamp: string;
 }
 
@@ -86,7 +94,10 @@ export interface Round {
     Question: Question;
     AnswerUUID: string;
     answer: string;
+    AnswerDetails?: Answer; // only from /games/{uuid}
+    QuestionOffers?: Question[]; // only in "choose" mode, Question is empty until the player chooses, see ChooseQuestion()
     Eliminations: Elimination[];
+    WitnessRefused: boolean; // witness did not answer YES or NO, next Round can go without eliminations
     Timestamp: string;
 }
 
@@ -114,6 +125,34 @@ export interface Question {
     Polish: string;
     Topic: string;
     Level: number;
+    Approved?: boolean; // custom questions written by players are not approved until an admin does so
+}
+
+export interface Description {
+    UUID: string;
+    SuspectUUID: string;
+    Service: string;
+    Model: string;
+    ProviderModel: string;
+    Description: string;
+    Timestamp: string;
+}
+
+export interface RevealRound {
+    RoundUUID: string;
+    Question: Question;
+    Verdict: string;
+    Reflection: string;
+    Eliminations: Elimination[];
+}
+
+// What was hidden during the investigation, available once it is over
+export interface Reveal {
+    InvestigationUUID: string;
+    Criminal: Suspect;
+    CriminalReleased: boolean;
+    Description: Description | null; // how the witness saw the criminal
+    Rounds: RevealRound[];
 }
 
 
@@ -141,10 +180,11 @@ export async function NewGame(model: string): Promise<Game> {
     if (!lastRoundUUID) {
         throw new Error('Last Round UUID not found in new game');
     }
+    if (!newGame.investigation.rounds.at(-1)?.Question?.UUID) return newGame; // answered after ChooseQuestion()
     const answer = await getOrGenerateAnswer(lastRoundUUID);
 
     if (newGame.investigation.rounds.at(-1)) {
-        const answerText = answer?.Text;
+        const answerText = answer?.Verdict || answer?.Text;
         if (!answerText) {
             throw new Error('Generated answer is empty');
         }
@@ -185,10 +225,11 @@ export async function NextRound() {
     if (!lastRoundUUID) {
         throw new Error('Last Round UUID not found in new game');
     }
+    if (!game.investigation.rounds.at(-1)?.Question?.UUID) return; // answered after ChooseQuestion()
     const answer = await getOrGenerateAnswer(lastRoundUUID);
 
     if (game.investigation.rounds.at(-1)) {
-        const answerText = answer?.Text;
+        const answerText = answer?.Verdict || answer?.Text;
         if (!answerText) {
             throw new Error('Generated answer is empty');
         }
@@ -217,10 +258,11 @@ export async function NextInvestigation() {
     if (!lastRoundUUID) {
         throw new Error('Last Round UUID not found in new game');
     }
+    if (!game.investigation.rounds.at(-1)?.Question?.UUID) return; // answered after ChooseQuestion()
     const answer = await getOrGenerateAnswer(lastRoundUUID);
 
     if (game.investigation.rounds.at(-1)) {
-        const answerText = answer?.Text;
+        const answerText = answer?.Verdict || answer?.Text;
         if (!answerText) {
             throw new Error('Generated answer is empty');
         }
@@ -232,6 +274,68 @@ export async function NextInvestigation() {
     currentGame.set(game);
 }
 
+// Choose the Question of the current Round from its QuestionOffers, then wait for the witness to answer it.
+export async function ChooseQuestion(roundUUID: string, questionUUID: string) {
+    const response = await fetch(`${API_URL}/choose_question?round_uuid=${roundUUID}&question_uuid=${questionUUID}`, initPOST);
+    if (!response.ok) {
+        throw new Error('Failed to choose question');
+    }
+
+    let game: Game = await GetGame();
+    currentGame.set(game);
+
+    const answer = await getOrGenerateAnswer(roundUUID);
+    const answerText = answer?.Verdict || answer?.Text;
+    if (!answerText) {
+        throw new Error('Generated answer is empty');
+    }
+    game = await GetGame();
+    currentGame.set(game);
+}
+
+// Ask own Question in the current Round instead of choosing one of its QuestionOffers, only in "custom" mode.
+// Question refused by the moderation is reported in the thrown error.
+export async function AskQuestion(roundUUID: string, question: string) {
+    const response = await fetch(`${API_URL}/ask_question?round_uuid=${roundUUID}&question=${encodeURIComponent(question)}`, initPOST);
+    if (!response.ok) {
+        const refusal = await response.json().catch(() => undefined);
+        throw new Error(refusal?.message ?? 'Failed to ask question');
+    }
+
+    let game: Game = await GetGame();
+    currentGame.set(game);
+
+    const answer = await getOrGenerateAnswer(roundUUID);
+    const answerText = answer?.Verdict || answer?.Text;
+    if (!answerText) {
+        throw new Error('Generated answer is empty');
+    }
+    game = await GetGame();
+    currentGame.set(game);
+}
+
+// Decision of the AI investigator in "reverse" mode, which suspects it eliminated based on the player's answer.
+export interface InvestigatorDecision {
+    UUID: string;
+    RoundUUID: string;
+    Eliminated: string[]; // UUIDs of eliminated suspects
+    Reasoning: string;
+    Model: string;
+}
+
+// Answer the question of the current Round as the witness in "reverse" mode, the AI investigator eliminates afterwards.
+// Criminal the player describes is in investigation.Criminal.
+export async function AnswerQuestion(roundUUID: string, answer: 'YES' | 'NO'): Promise<InvestigatorDecision> {
+    const response = await fetch(`${API_URL}/answer_question?round_uuid=${roundUUID}&answer=${answer}`, initPOST);
+    if (!response.ok) {
+        throw new Error('Failed to answer question');
+    }
+    const decision: InvestigatorDecision = await response.json();
+
+    currentGame.set(await GetGame());
+    return decision;
+}
+
 export async function EliminateSuspect(suspectUUID: string, roundUUID: string, investigationUUID: string): Promise<void> {
     const response = await fetch(`${API_URL}/eliminate_suspect?suspect_uuid=${suspectUUID}&round_uuid=${roundUUID}&investigation_uuid=${investigationUUID}`, initPOST);
     if (!response.ok) {
@@ -239,12 +343,21 @@ export async function EliminateSuspect(suspectUUID: string, roundUUID: string, i
     }
 }
 
-export async function WaitForAnswer(roundUUID: string): Promise<string> {
+export async function WaitForAnswer(roundUUID: string): Promise<Answer> {
     const response = await fetch(`${API_URL}/wait_for_answer?round_uuid=${roundUUID}`, initGET);
     if (!response.ok) {
         throw new Error('Failed to wait for answer');
     }
 
+    return await response.json() as Answer;
+}
+
+export async function GetReveal(investigationUUID: string): Promise<Reveal> {
+    const response = await fetch(`${API_URL}/reveal?investigation_uuid=${investigationUUID}`, initGET);
+    if (!response.ok) {
+        throw new Error('Failed to fetch reveal');
+    }
+
     return await response.json();
 }
 
@@ -280,15 +393,15 @@ export async function ListAvailableModels(allowedOnly: boolean, orderBy: string)
 }
 
 export async function getOrGenerateAnswer(roundUUID: string): Promise<Answer|undefined> {
-    const player = get(currentPlayer);
     console.log(`>>> getOrGenerateAnswer called! roundUUID=${roundUUID}`);
     try {
         let answer: Answer; 
-        const response = await fetch(`${API_URL}/get_or_generate_answer?player_uuid=${player.UUID}`, initGET);
+        // Answer is generated in the background since the Round was created, just wait for it
+        const response = await fetch(`${API_URL}/wait_for_answer?round_uuid=${roundUUID}`, initGET);
         // Teapot means AI failed - this can happen as LLM reasoning is not perfect
         if (response.status === 418) {
             const bodyText = await response.text();
-            console.log(`Request /get_or_generate_answer returned 418 (I'm a teapot) - AI failed to answer the question: ${bodyText}`);
+            console.log(`Request /wait_for_answer returned 418 (I'm a teapot) - AI failed to answer the question: ${bodyText}`);
             return { UUID: '', Text: '__AI_FAILED__', Timestamp: new Date().toISOString() } as Answer;
         // RN we use just 500 code which is for database and other unexpected errors
         } else if (!response.ok) { 
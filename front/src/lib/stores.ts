@@ -17,6 +17,7 @@ const defaultGame: Game = {
         InvestigationOver: false,
         Timestamp: ''
     },
+    Status: 'awaiting_answer',
     GameOver: false,
     Investigator: '',
     Model: '',